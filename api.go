@@ -2,145 +2,371 @@
 package ollamago
 
 import (
-	"bufio"
 	"context"
-	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"net/http"
+	"time"
 )
 
-// Generate creates a completion using the specified model
+// Generate creates a completion using the specified model. The request,
+// including its slices and options, is never modified.
 func (c *Client) Generate(ctx context.Context, req GenerateRequest) (*GenerateResponse, error) {
 	if req.Model == "" {
 		return nil, &RequestError{Message: "model is required"}
 	}
+	req = req.clone()
+	c.applyKeepAlive(&req.KeepAlive)
 	req.Stream = false
+	opts, err := c.prepareOptions(req.Options)
+	if err != nil {
+		return nil, err
+	}
+	req.Options = opts
+	if err := c.compatGenerate(ctx, &req); err != nil {
+		return nil, err
+	}
 
 	var resp GenerateResponse
-	if err := c.request(ctx, http.MethodPost, "/api/generate", req, &resp, false); err != nil {
+	err = c.request(ctx, http.MethodPost, "/api/generate", req, &resp, false)
+	if err != nil && c.autoTrim && errors.Is(err, ErrContextLengthExceeded) {
+		err = c.retryTrimmedGenerate(ctx, &req, &resp, err)
+	}
+	if err != nil {
 		return nil, err
 	}
+	c.usage.add(resp.Usage())
+	if c.echoRequest {
+		resp.Request = &req
+	}
+	if c.returnPrompt {
+		prompt, err := c.renderGenerate(ctx, req)
+		if err != nil {
+			return nil, fmt.Errorf("reconstructing prompt: %w", err)
+		}
+		resp.Prompt = prompt
+	}
+	if c.errOnEmpty && resp.Response == "" && !isLoadReason(resp.DoneReason) {
+		return nil, fmt.Errorf("%w (done_reason %q)", ErrEmptyResponse, resp.DoneReason)
+	}
 	return &resp, nil
 }
 
-// GenerateStream creates a streaming completion for the provided prompt
+// isLoadReason reports whether a done reason comes from a request that only
+// loads or unloads a model, where an empty response is expected
+func isLoadReason(reason string) bool {
+	return reason == "load" || reason == "unload"
+}
+
+// GenerateStream creates a streaming completion for the provided prompt.
+//
+// Chunks are sent on the first channel, which is closed when the stream
+// ends. The terminal error, including a cancelled context, is delivered on
+// the second channel before the first is closed, so range over the chunks
+// and then receive once from the error channel; a nil error means the
+// stream completed. The same contract holds for every streaming method.
+//
+// The final chunk, with Done set, is the only one carrying the Context
+// needed to continue the conversation, and the metrics, so keep reading
+// until the channel closes rather than stopping at the first Done, or use
+// GenerateAccumulate or StartGenerate, whose response includes it. Anything
+// the server sends after the final chunk is merged into it rather than
+// dropped, so the final chunk is delivered once the response body ends or
+// the wait set with WithTrailingFrameWait runs out.
 func (c *Client) GenerateStream(ctx context.Context, req GenerateRequest) (<-chan GenerateResponse, <-chan error) {
 	responseChan := make(chan GenerateResponse)
 	errChan := make(chan error, 1)
+	// Copy before returning so later changes by the caller cannot race
+	req = req.clone()
+	c.applyKeepAlive(&req.KeepAlive)
 
 	go func() {
-		defer close(responseChan)
+		// Close the error channel last so it holds the terminal error, if
+		// any, by the time the response channel is closed
 		defer close(errChan)
+		defer close(responseChan)
 
 		if req.Model == "" {
 			errChan <- &RequestError{Message: "model is required"}
 			return
 		}
 
+		opts, err := c.prepareOptions(req.Options)
+		if err != nil {
+			errChan <- err
+			return
+		}
+		req.Options = opts
+		if err := c.compatGenerate(ctx, &req); err != nil {
+			errChan <- err
+			return
+		}
+
 		req.Stream = true
+		parent := ctx
+		ctx, watchdog, stop := c.streamContext(ctx)
+		defer stop()
+		ctx, endTrailing := context.WithCancelCause(ctx)
+		defer endTrailing(nil)
 		resp, err := c.requestStream(ctx, http.MethodPost, "/api/generate", req)
 		if err != nil {
+			if ctx.Err() != nil {
+				err = context.Cause(ctx)
+			}
 			errChan <- err
 			return
 		}
+		resp.Body = watchdog.watch(resp.Body)
 		defer resp.Body.Close()
-
-		scanner := bufio.NewScanner(resp.Body)
-		for scanner.Scan() {
+		c.meter.start()
+		defer c.meter.end()
+
+		asserter := c.newStreamAsserter()
+		// The done frame is held until the body ends or the trailing frame
+		// wait runs out, so that any data trailing it is merged in rather
+		// than lost
+		var final *GenerateResponse
+		send := func(genResp GenerateResponse) bool {
+			watchdog.pause()
 			select {
-			case <-ctx.Done():
-				errChan <- ctx.Err()
-				return
-			default:
-				line := scanner.Bytes()
-				if len(line) == 0 {
-					continue
-				}
+			case responseChan <- genResp:
+			case <-parent.Done():
+				errChan <- context.Cause(parent)
+				return false
+			}
+			watchdog.progress()
+			if genResp.Done {
+				c.usage.add(genResp.Usage())
+			}
+			if genResp.Response != "" || genResp.Thinking != "" {
+				c.meter.record(time.Now())
+			}
+			return true
+		}
 
-				var genResp GenerateResponse
-				if err := json.Unmarshal(line, &genResp); err != nil {
-					errChan <- fmt.Errorf("failed to decode response: %w", err)
+		// Frames are decoded straight from the body rather than line by
+		// line, so the final frame is never cut off by a line limit however
+		// long its context is
+		decoder := c.newDecoder(resp.Body)
+		for {
+			var genResp GenerateResponse
+			if err := decoder.Decode(&genResp); err != nil {
+				if final != nil && finished(parent, ctx) {
+					// The generation is complete even if reading past it
+					// failed or stalled
+					send(*final)
 					return
 				}
-
-				select {
-				case responseChan <- genResp:
-				case <-ctx.Done():
-					errChan <- ctx.Err()
+				if final != nil {
+					// Cancelled before the final chunk was delivered
+					errChan <- context.Cause(ctx)
 					return
 				}
-
-				if genResp.Done {
+				if err == io.EOF {
+					if err := asserter.finish(); err != nil {
+						errChan <- err
+					}
+					return
+				}
+				// A cancelled context surfaces as a read error on the body
+				if ctx.Err() != nil {
+					errChan <- context.Cause(ctx)
 					return
 				}
+				errChan <- fmt.Errorf("failed to decode response: %w", err)
+				return
+			}
+			if err := asserter.frame(genResp.Done, genResp.PromptEvalCount, genResp.EvalCount); err != nil {
+				errChan <- err
+				return
 			}
-		}
 
-		if err := scanner.Err(); err != nil {
-			errChan <- fmt.Errorf("error reading response: %w", err)
+			switch {
+			case final != nil:
+				final.merge(genResp)
+			case genResp.Done && asserter == nil && c.trailingWait > 0:
+				final = &genResp
+				trailing := time.AfterFunc(c.trailingWait, func() { endTrailing(errTrailingWait) })
+				defer trailing.Stop()
+			case genResp.Done && asserter == nil:
+				send(genResp)
+				return
+			default:
+				if !send(genResp) {
+					return
+				}
+			}
 		}
 	}()
 
 	return responseChan, errChan
 }
 
-// Chat creates a chat completion using the specified model and messages
+// Chat creates a chat completion using the specified model and messages.
+// The request, including its messages and options, is never modified.
 func (c *Client) Chat(ctx context.Context, req ChatRequest) (*ChatResponse, error) {
 	if req.Model == "" {
 		return nil, &RequestError{Message: "model is required"}
 	}
+	req = req.clone()
+	c.applyKeepAlive(&req.KeepAlive)
 	req.Stream = false
+	opts, err := c.prepareOptions(req.Options)
+	if err != nil {
+		return nil, err
+	}
+	req.Options = opts
+	if err := c.compatChat(ctx, &req); err != nil {
+		return nil, err
+	}
+
 	var resp ChatResponse
-	if err := c.request(ctx, http.MethodPost, "/api/chat", req, &resp, false); err != nil {
+	err = c.request(ctx, http.MethodPost, "/api/chat", req, &resp, false)
+	if err != nil && c.autoTrim && errors.Is(err, ErrContextLengthExceeded) {
+		err = c.retryTrimmedChat(ctx, &req, &resp, err)
+	}
+	if err != nil {
 		return nil, err
 	}
+	c.usage.add(resp.Usage())
+	if c.echoRequest {
+		resp.Request = &req
+	}
+	if c.returnPrompt {
+		prompt, err := c.RenderChat(ctx, req)
+		if err != nil {
+			return nil, fmt.Errorf("reconstructing prompt: %w", err)
+		}
+		resp.Prompt = prompt
+	}
+	if c.errOnEmpty && resp.Message.Content == "" && len(resp.Message.ToolCalls) == 0 && !isLoadReason(resp.DoneReason) {
+		return nil, fmt.Errorf("%w (done_reason %q)", ErrEmptyResponse, resp.DoneReason)
+	}
 
 	return &resp, nil
 }
 
-// ChatStream creates a streaming chat completion
+// ChatStream creates a streaming chat completion. See GenerateStream for
+// how to consume the channels.
 func (c *Client) ChatStream(ctx context.Context, req ChatRequest) (<-chan ChatResponse, <-chan error) {
 	respChan := make(chan ChatResponse)
 	errChan := make(chan error, 1)
+	// Copy before returning so later changes by the caller cannot race
+	req = req.clone()
+	c.applyKeepAlive(&req.KeepAlive)
 
 	go func() {
-		defer close(respChan)
+		// Close the error channel last so it holds the terminal error, if
+		// any, by the time the response channel is closed
 		defer close(errChan)
+		defer close(respChan)
 
 		if req.Model == "" {
 			errChan <- &RequestError{Message: "model is required"}
 			return
 		}
 
+		opts, err := c.prepareOptions(req.Options)
+		if err != nil {
+			errChan <- err
+			return
+		}
+		req.Options = opts
+		if err := c.compatChat(ctx, &req); err != nil {
+			errChan <- err
+			return
+		}
+
 		req.Stream = true
+		parent := ctx
+		ctx, watchdog, stop := c.streamContext(ctx)
+		defer stop()
+		ctx, endTrailing := context.WithCancelCause(ctx)
+		defer endTrailing(nil)
 		resp, err := c.requestStream(ctx, http.MethodPost, "/api/chat", req)
 		if err != nil {
+			if ctx.Err() != nil {
+				err = context.Cause(ctx)
+			}
 			errChan <- err
 			return
 		}
+		resp.Body = watchdog.watch(resp.Body)
 		defer resp.Body.Close()
+		c.meter.start()
+		defer c.meter.end()
+
+		asserter := c.newStreamAsserter()
+		// The done frame is held until the body ends or the trailing frame
+		// wait runs out, so that any data trailing it is merged in rather
+		// than lost
+		var final *ChatResponse
+		send := func(chatResp ChatResponse) bool {
+			watchdog.pause()
+			select {
+			case respChan <- chatResp:
+			case <-parent.Done():
+				errChan <- context.Cause(parent)
+				return false
+			}
+			watchdog.progress()
+			if chatResp.Done {
+				c.usage.add(chatResp.Usage())
+			}
+			if chatResp.Message.Content != "" || chatResp.Message.Thinking != "" || len(chatResp.Message.ToolCalls) > 0 {
+				c.meter.record(time.Now())
+			}
+			return true
+		}
 
-		decoder := json.NewDecoder(resp.Body)
+		decoder := c.newDecoder(resp.Body)
 		for {
 			var chatResp ChatResponse
 			if err := decoder.Decode(&chatResp); err != nil {
+				if final != nil && finished(parent, ctx) {
+					// The chat is complete even if reading past it failed
+					// or stalled
+					send(*final)
+					return
+				}
+				if final != nil {
+					// Cancelled before the final chunk was delivered
+					errChan <- context.Cause(ctx)
+					return
+				}
 				if err == io.EOF {
+					if err := asserter.finish(); err != nil {
+						errChan <- err
+					}
+					return
+				}
+				if ctx.Err() != nil {
+					errChan <- context.Cause(ctx)
 					return
 				}
 				errChan <- fmt.Errorf("decode error: %w", err)
 				return
 			}
-
-			select {
-			case respChan <- chatResp:
-			case <-ctx.Done():
-				errChan <- ctx.Err()
+			if err := asserter.frame(chatResp.Done, chatResp.PromptEvalCount, chatResp.EvalCount); err != nil {
+				errChan <- err
 				return
 			}
 
-			if chatResp.Done {
+			switch {
+			case final != nil:
+				final.merge(chatResp)
+			case chatResp.Done && asserter == nil && c.trailingWait > 0:
+				final = &chatResp
+				trailing := time.AfterFunc(c.trailingWait, func() { endTrailing(errTrailingWait) })
+				defer trailing.Stop()
+			case chatResp.Done && asserter == nil:
+				send(chatResp)
 				return
+			default:
+				if !send(chatResp) {
+					return
+				}
 			}
 		}
 	}()
@@ -153,29 +379,142 @@ func (c *Client) Embeddings(ctx context.Context, req EmbeddingsRequest) (*Embedd
 	if req.Model == "" {
 		return nil, &RequestError{Message: "model is required"}
 	}
+	c.applyKeepAlive(&req.KeepAlive)
 
 	var resp EmbeddingsResponse
 	if err := c.request(ctx, http.MethodPost, "/api/embeddings", req, &resp, false); err != nil {
 		return nil, err
 	}
+	if len(resp.Embedding) == 0 {
+		return nil, fmt.Errorf("%w: no embedding returned", ErrEmptyResponse)
+	}
 
 	return &resp, nil
 }
 
-// CreateModel creates a model from a Modelfile
+// Embed generates embeddings for one or more inputs in a single request
+func (c *Client) Embed(ctx context.Context, req EmbedRequest) (*EmbedResponse, error) {
+	if req.Model == "" {
+		return nil, &RequestError{Message: "model is required"}
+	}
+	switch input := req.Input.(type) {
+	case string:
+	case []string:
+		if len(input) == 0 {
+			return nil, &RequestError{Message: "input is required"}
+		}
+	default:
+		return nil, &RequestError{Message: "input must be a string or []string"}
+	}
+
+	c.applyKeepAlive(&req.KeepAlive)
+	if err := c.compatEmbed(ctx, &req); err != nil {
+		return nil, err
+	}
+	var resp EmbedResponse
+	if err := c.request(ctx, http.MethodPost, "/api/embed", req, &resp, false); err != nil {
+		return nil, err
+	}
+	if len(resp.Embeddings) == 0 {
+		return nil, fmt.Errorf("%w: no embeddings returned", ErrEmptyResponse)
+	}
+	if c.validateEmbeddings {
+		if err := resp.Validate(); err != nil {
+			return nil, err
+		}
+	}
+
+	return &resp, nil
+}
+
+// CreateModel creates a model from a Modelfile. When Path names a local
+// model file, it is uploaded as a blob if the server does not have it yet
+// and the Modelfile is pointed at it.
 func (c *Client) CreateModel(ctx context.Context, req CreateModelRequest) (*ProgressResponse, error) {
 	if req.Name == "" {
 		return nil, &RequestError{Message: "model name is required"}
 	}
 
+	if req.Path != "" {
+		digest, err := c.uploadFile(ctx, req.Path)
+		if err != nil {
+			return nil, err
+		}
+		req.Modelfile = modelfileFromBlob(req.Modelfile, req.Path, digest)
+	}
+
+	// A streamed reply would be cut to its first frame, use CreateModelStream
+	req.Stream = false
 	var resp ProgressResponse
-	if err := c.request(ctx, http.MethodPost, "/api/create", req, &resp, req.Stream); err != nil {
+	if err := c.request(ctx, http.MethodPost, "/api/create", req, &resp, false); err != nil {
 		return nil, err
 	}
 
 	return &resp, nil
 }
 
+// CreateModelStream creates a model like CreateModel, with progress updates.
+// An error the server reports in a progress frame ends the stream and is
+// delivered on the error channel as a ResponseError.
+func (c *Client) CreateModelStream(ctx context.Context, req CreateModelRequest) (<-chan ProgressResponse, <-chan error) {
+	respChan := make(chan ProgressResponse)
+	errChan := make(chan error, 1)
+
+	go func() {
+		// Close the error channel last so it holds the terminal error, if
+		// any, by the time the response channel is closed
+		defer close(errChan)
+		defer close(respChan)
+
+		if req.Name == "" {
+			errChan <- &RequestError{Message: "model name is required"}
+			return
+		}
+
+		if req.Path != "" {
+			digest, err := c.uploadFile(ctx, req.Path)
+			if err != nil {
+				errChan <- err
+				return
+			}
+			req.Modelfile = modelfileFromBlob(req.Modelfile, req.Path, digest)
+		}
+
+		req.Stream = true
+		resp, err := c.requestStream(ctx, http.MethodPost, "/api/create", req)
+		if err != nil {
+			errChan <- err
+			return
+		}
+		defer resp.Body.Close()
+
+		decoder := c.newDecoder(resp.Body)
+		for {
+			var progressResp ProgressResponse
+			if err := decoder.Decode(&progressResp); err != nil {
+				if err == io.EOF {
+					return
+				}
+				errChan <- fmt.Errorf("decode error: %w", err)
+				return
+			}
+			if progressResp.Error != "" {
+				errChan <- &ResponseError{StatusCode: resp.StatusCode, Message: progressResp.Error, Header: resp.Header}
+				return
+			}
+
+			select {
+			case respChan <- progressResp:
+			case <-ctx.Done():
+				errChan <- ctx.Err()
+				return
+			}
+		}
+	}()
+
+	return respChan, errChan
+}
+
 // ListModels returns a list of local models
 func (c *Client) ListModels(ctx context.Context) (*ListModelsResponse, error) {
 	var resp ListModelsResponse
@@ -186,6 +525,40 @@ func (c *Client) ListModels(ctx context.Context) (*ListModelsResponse, error) {
 	return &resp, nil
 }
 
+// Ping checks that the Ollama server is reachable at the client's base URL
+func (c *Client) Ping(ctx context.Context) error {
+	resp, err := c.do(ctx, http.MethodGet, "/", nil)
+	if err != nil {
+		return fmt.Errorf("can't reach Ollama at %s: %w", c.baseURL, err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("can't reach Ollama at %s: %w", c.baseURL, c.responseError(resp))
+	}
+	return nil
+}
+
+// Version returns the version of the Ollama server
+func (c *Client) Version(ctx context.Context) (*VersionResponse, error) {
+	var resp VersionResponse
+	if err := c.request(ctx, http.MethodGet, "/api/version", nil, &resp, false); err != nil {
+		return nil, err
+	}
+
+	return &resp, nil
+}
+
+// ListRunningModels returns the models currently loaded into memory
+func (c *Client) ListRunningModels(ctx context.Context) (*RunningModelsResponse, error) {
+	var resp RunningModelsResponse
+	if err := c.request(ctx, http.MethodGet, "/api/ps", nil, &resp, false); err != nil {
+		return nil, err
+	}
+
+	return &resp, nil
+}
+
 // ShowModel shows details about the specified model
 func (c *Client) ShowModel(ctx context.Context, req ShowModelRequest) (*ShowModelResponse, error) {
 	if req.Name == "" {
@@ -248,8 +621,10 @@ func (c *Client) PullModelStream(ctx context.Context, req PullModelRequest) (<-c
 	errChan := make(chan error, 1)
 
 	go func() {
-		defer close(respChan)
+		// Close the error channel last so it holds the terminal error, if
+		// any, by the time the response channel is closed
 		defer close(errChan)
+		defer close(respChan)
 
 		if req.Name == "" {
 			errChan <- &RequestError{Message: "model name is required"}
@@ -264,7 +639,7 @@ func (c *Client) PullModelStream(ctx context.Context, req PullModelRequest) (<-c
 		}
 		defer resp.Body.Close()
 
-		decoder := json.NewDecoder(resp.Body)
+		decoder := c.newDecoder(resp.Body)
 		for {
 			var progressResp ProgressResponse
 			if err := decoder.Decode(&progressResp); err != nil {
@@ -307,8 +682,10 @@ func (c *Client) PushModelStream(ctx context.Context, req PushModelRequest) (<-c
 	errChan := make(chan error, 1)
 
 	go func() {
-		defer close(respChan)
+		// Close the error channel last so it holds the terminal error, if
+		// any, by the time the response channel is closed
 		defer close(errChan)
+		defer close(respChan)
 
 		if req.Name == "" {
 			errChan <- &RequestError{Message: "model name is required"}
@@ -323,7 +700,7 @@ func (c *Client) PushModelStream(ctx context.Context, req PushModelRequest) (<-c
 		}
 		defer resp.Body.Close()
 
-		decoder := json.NewDecoder(resp.Body)
+		decoder := c.newDecoder(resp.Body)
 		for {
 			var progressResp ProgressResponse
 			if err := decoder.Decode(&progressResp); err != nil {
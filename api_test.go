@@ -0,0 +1,369 @@
+// api_test.go
+package ollamago
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"slices"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestRequestsAreNotModified(t *testing.T) {
+	var (
+		mu    sync.Mutex
+		calls = make(map[string]int)
+	)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		mu.Lock()
+		calls[r.URL.Path]++
+		n := calls[r.URL.Path]
+		mu.Unlock()
+		switch r.URL.Path {
+		case "/api/version":
+			fmt.Fprint(w, `{"version":"0.2.0"}`)
+		case "/api/show":
+			fmt.Fprint(w, `{"model_info":{"llama.context_length":64}}`)
+		default:
+			if n == 1 {
+				w.WriteHeader(http.StatusBadRequest)
+				fmt.Fprint(w, `{"error":"input length exceeds the context length"}`)
+				return
+			}
+			fmt.Fprint(w, `{"response":"ok","message":{"role":"assistant","content":"ok"},"done":true}`)
+		}
+	}))
+	defer srv.Close()
+
+	c := NewClient(
+		WithBaseURL(srv.URL),
+		WithKeepAlive(time.Minute),
+		WithAutoTrimOnOverflow(true),
+		WithVersionCompat(VersionCompatStrip),
+	)
+	think := true
+	long := strings.Repeat("word ", 40)
+
+	genReq := GenerateRequest{
+		Model:        "m",
+		Prompt:       long,
+		Context:      []int{1, 2, 3},
+		FormatSchema: json.RawMessage(`{"type":"object"}`),
+		Images:       []Image{ImageFromBytes([]byte("image"))},
+		Options:      &Options{Temperature: ptr(0.2), Stop: []string{"a", "a"}},
+		Think:        &think,
+	}
+	wantGen := genReq.clone()
+	if _, err := c.Generate(context.Background(), genReq); err != nil {
+		t.Fatalf("Generate: %v", err)
+	}
+	if !reflect.DeepEqual(genReq, wantGen) {
+		t.Errorf("generate request changed:\n%+v\nwant\n%+v", genReq, wantGen)
+	}
+
+	chatReq := ChatRequest{
+		Model: "m",
+		Messages: []Message{
+			{Role: "system", Content: "be brief"},
+			{Role: "user", Content: long},
+			{Role: "assistant", Content: long},
+			{Role: "user", Content: "hi", Images: []Image{ImageFromBytes([]byte("image"))}},
+		},
+		Format:  `{"type":"object"}`,
+		Tools:   []Tool{{Type: "function", Function: Function{Name: "f", Parameters: json.RawMessage(`{}`)}}},
+		Options: &Options{Stop: []string{"a", "a"}},
+		Think:   &think,
+	}
+	wantChat := chatReq.clone()
+	if _, err := c.Chat(context.Background(), chatReq); err != nil {
+		t.Fatalf("Chat: %v", err)
+	}
+	if !reflect.DeepEqual(chatReq, wantChat) {
+		t.Errorf("chat request changed:\n%+v\nwant\n%+v", chatReq, wantChat)
+	}
+
+	if calls["/api/generate"] != 2 || calls["/api/chat"] != 2 {
+		t.Errorf("calls = %v, want an overflow and a trimmed retry for each", calls)
+	}
+}
+
+// blockingServer answers generate and chat requests only once release is
+// closed
+func blockingServer(t *testing.T) *httptest.Server {
+	t.Helper()
+	release := make(chan struct{})
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-release:
+		case <-r.Context().Done():
+		}
+	}))
+	t.Cleanup(srv.Close)
+	t.Cleanup(func() { close(release) })
+	return srv
+}
+
+func TestCancelInFlight(t *testing.T) {
+	srv := blockingServer(t)
+	c := NewClient(WithBaseURL(srv.URL))
+
+	calls := map[string]func(context.Context) error{
+		"generate": func(ctx context.Context) error {
+			_, err := c.Generate(ctx, GenerateRequest{Model: "m", Prompt: "hi"})
+			return err
+		},
+		"chat": func(ctx context.Context) error {
+			_, err := c.Chat(ctx, ChatRequest{Model: "m"})
+			return err
+		},
+	}
+	for name, call := range calls {
+		t.Run(name, func(t *testing.T) {
+			ctx, cancel := context.WithCancel(context.Background())
+			time.AfterFunc(100*time.Millisecond, cancel)
+
+			start := time.Now()
+			err := call(ctx)
+			if !errors.Is(err, context.Canceled) {
+				t.Errorf("err = %v, want context.Canceled", err)
+			}
+			if elapsed := time.Since(start); elapsed > 2*time.Second {
+				t.Errorf("call returned %v after cancel", elapsed)
+			}
+		})
+	}
+}
+
+func TestCancelGroup(t *testing.T) {
+	srv := blockingServer(t)
+	c := NewClient(WithBaseURL(srv.URL))
+	group := NewCancelGroup()
+
+	errs := make(chan error, 2)
+	for _, prompt := range []string{"one", "two"} {
+		go func() {
+			ctx, cancel := group.Context(context.Background())
+			defer cancel()
+			_, err := c.Generate(ctx, GenerateRequest{Model: "m", Prompt: prompt})
+			errs <- err
+		}()
+	}
+
+	time.Sleep(100 * time.Millisecond)
+	group.Cancel()
+	for i := 0; i < 2; i++ {
+		select {
+		case err := <-errs:
+			if !errors.Is(err, context.Canceled) {
+				t.Errorf("err = %v, want context.Canceled", err)
+			}
+		case <-time.After(2 * time.Second):
+			t.Fatal("request still in flight after Cancel")
+		}
+	}
+
+	select {
+	case <-group.Done():
+	default:
+		t.Error("Done is not closed after Cancel")
+	}
+
+	// Contexts derived after Cancel start out cancelled
+	ctx, cancel := group.Context(context.Background())
+	defer cancel()
+	if ctx.Err() == nil {
+		t.Error("context derived after Cancel is not done")
+	}
+}
+
+func TestRequestTimeout(t *testing.T) {
+	srv := blockingServer(t)
+	c := NewClient(WithBaseURL(srv.URL), WithTimeout(100*time.Millisecond))
+
+	if _, err := c.Generate(context.Background(), GenerateRequest{Model: "m", Prompt: "hi"}); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("err = %v, want context.DeadlineExceeded", err)
+	}
+
+	// A context with its own deadline keeps it
+	ctx, cancel := context.WithCancel(context.Background())
+	time.AfterFunc(300*time.Millisecond, cancel)
+	ctx, cancelDeadline := context.WithTimeout(ctx, time.Minute)
+	defer cancelDeadline()
+	if _, err := c.Generate(ctx, GenerateRequest{Model: "m", Prompt: "hi"}); !errors.Is(err, context.Canceled) {
+		t.Errorf("err = %v, want context.Canceled from the caller's context", err)
+	}
+}
+
+func TestRequestTimeoutSkipsStreams(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/x-ndjson")
+		for i := 0; i < 3; i++ {
+			fmt.Fprintln(w, `{"response":"x"}`)
+			w.(http.Flusher).Flush()
+			time.Sleep(60 * time.Millisecond)
+		}
+		fmt.Fprintln(w, `{"done":true}`)
+	}))
+	defer srv.Close()
+
+	c := NewClient(WithBaseURL(srv.URL), WithTimeout(50*time.Millisecond))
+	resp, err := c.GenerateAccumulate(context.Background(), GenerateRequest{Model: "m", Prompt: "hi"}, nil)
+	if err != nil {
+		t.Fatalf("stream ended early: %v", err)
+	}
+	if resp.Response != "xxx" {
+		t.Errorf("response = %q, want %q", resp.Response, "xxx")
+	}
+}
+
+func TestEchoRequest(t *testing.T) {
+	var sent []byte
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var err error
+		if sent, err = io.ReadAll(r.Body); err != nil {
+			t.Errorf("reading request: %v", err)
+		}
+		w.Header().Set("Content-Type", "application/json")
+		fmt.Fprint(w, `{"response":"ok","done":true}`)
+	}))
+	defer srv.Close()
+
+	req := GenerateRequest{
+		Model:   "m",
+		Prompt:  "hi",
+		Options: &Options{Stop: []string{"\n", "\n", "END"}},
+	}
+	for _, enabled := range []bool{true, false} {
+		t.Run(fmt.Sprint(enabled), func(t *testing.T) {
+			c := NewClient(WithBaseURL(srv.URL), WithKeepAlive(time.Minute), WithEchoRequest(enabled))
+			resp, err := c.Generate(context.Background(), req)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !enabled {
+				if resp.Request != nil {
+					t.Errorf("Request = %+v, want nil", resp.Request)
+				}
+				return
+			}
+
+			var want GenerateRequest
+			if err := json.Unmarshal(sent, &want); err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(resp.Request, &want) {
+				t.Errorf("Request = %+v, want the sent request %+v", resp.Request, want)
+			}
+			if resp.Request.KeepAlive == "" || len(resp.Request.Options.Stop) != 2 {
+				t.Errorf("Request = %+v, want keep alive and options prepared", resp.Request)
+			}
+		})
+	}
+}
+
+func TestErrorOnEmptyResponse(t *testing.T) {
+	tests := []struct {
+		name    string
+		body    string
+		wantErr bool
+	}{
+		{"empty", `{"response":"","done":true,"done_reason":"stop"}`, true},
+		{"non-empty", `{"response":"ok","done":true,"done_reason":"stop"}`, false},
+		{"load", `{"response":"","done":true,"done_reason":"load"}`, false},
+		{"unload", `{"response":"","done":true,"done_reason":"unload"}`, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.Header().Set("Content-Type", "application/json")
+				fmt.Fprint(w, tt.body)
+			}))
+			defer srv.Close()
+			c := NewClient(WithBaseURL(srv.URL), WithErrorOnEmptyResponse(true))
+
+			_, err := c.Generate(context.Background(), GenerateRequest{Model: "m", Prompt: "hi"})
+			if errors.Is(err, ErrEmptyResponse) != tt.wantErr {
+				t.Errorf("err = %v, want ErrEmptyResponse: %v", err, tt.wantErr)
+			}
+			if !tt.wantErr && err != nil {
+				t.Errorf("err = %v", err)
+			}
+		})
+	}
+
+	t.Run("chat with tool calls", func(t *testing.T) {
+		srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Content-Type", "application/json")
+			fmt.Fprint(w, `{"message":{"role":"assistant","tool_calls":[{"function":{"name":"f"}}]},"done":true}`)
+		}))
+		defer srv.Close()
+		c := NewClient(WithBaseURL(srv.URL), WithErrorOnEmptyResponse(true))
+
+		if _, err := c.Chat(context.Background(), ChatRequest{Model: "m"}); err != nil {
+			t.Errorf("err = %v, want tool calls to count as output", err)
+		}
+	})
+}
+
+func TestKeepAlive(t *testing.T) {
+	var sent []string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req struct {
+			KeepAlive string `json:"keep_alive"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			t.Errorf("decoding request: %v", err)
+		}
+		sent = append(sent, req.KeepAlive)
+		w.Header().Set("Content-Type", "application/json")
+		fmt.Fprint(w, `{"response":"ok","message":{"role":"assistant","content":"ok"},"embeddings":[[0.1,0.2]],"done":true}`)
+	}))
+	defer srv.Close()
+	ctx := context.Background()
+
+	c := NewClient(WithBaseURL(srv.URL), WithKeepAlive(10*time.Minute))
+	calls := []func(keepAlive string) error{
+		func(keepAlive string) error {
+			_, err := c.Generate(ctx, GenerateRequest{Model: "m", Prompt: "hi", KeepAlive: keepAlive})
+			return err
+		},
+		func(keepAlive string) error {
+			_, err := c.Chat(ctx, ChatRequest{Model: "m", KeepAlive: keepAlive})
+			return err
+		},
+		func(keepAlive string) error {
+			_, err := c.Embed(ctx, EmbedRequest{Model: "m", Input: "hi", KeepAlive: keepAlive})
+			return err
+		},
+	}
+	for _, call := range calls {
+		if err := call(""); err != nil {
+			t.Fatal(err)
+		}
+		if err := call("1h"); err != nil {
+			t.Fatal(err)
+		}
+	}
+	want := []string{"10m0s", "1h", "10m0s", "1h", "10m0s", "1h"}
+	if !slices.Equal(sent, want) {
+		t.Errorf("keep_alive sent = %q, want %q", sent, want)
+	}
+
+	// Without the option the server's default applies
+	sent = nil
+	if _, err := NewClient(WithBaseURL(srv.URL)).Generate(ctx, GenerateRequest{Model: "m", Prompt: "hi"}); err != nil {
+		t.Fatal(err)
+	}
+	if !slices.Equal(sent, []string{""}) {
+		t.Errorf("keep_alive sent = %q, want none", sent)
+	}
+}
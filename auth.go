@@ -0,0 +1,33 @@
+// auth.go
+package ollamago
+
+import (
+	"net/http"
+)
+
+// WithBearerToken authenticates every request with an
+// "Authorization: Bearer <token>" header, for Ollama behind an
+// authenticating proxy
+func WithBearerToken(token string) Option {
+	return WithAuthFunc(func(req *http.Request) error {
+		req.Header.Set("Authorization", "Bearer "+token)
+		return nil
+	})
+}
+
+// WithBasicAuth authenticates every request with HTTP basic authentication
+func WithBasicAuth(username, password string) Option {
+	return WithAuthFunc(func(req *http.Request) error {
+		req.SetBasicAuth(username, password)
+		return nil
+	})
+}
+
+// WithAuthFunc calls fn on every outgoing request before it is sent, so
+// credentials can be fetched or refreshed per request. An error from fn
+// aborts the request.
+func WithAuthFunc(fn func(*http.Request) error) Option {
+	return func(c *Client) {
+		c.authFunc = fn
+	}
+}
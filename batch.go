@@ -0,0 +1,220 @@
+// batch.go
+package ollamago
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// WarmupAll loads several models into memory, at most concurrency at a
+// time, and returns how long each took to load. Models that fail to load
+// are missing from the map and reported together in the error.
+func (c *Client) WarmupAll(ctx context.Context, models []string, concurrency int) (map[string]time.Duration, error) {
+	if concurrency < 1 {
+		concurrency = 1
+	}
+
+	var (
+		mu        sync.Mutex
+		wg        sync.WaitGroup
+		durations = make(map[string]time.Duration, len(models))
+		errs      []error
+		sem       = make(chan struct{}, concurrency)
+	)
+	for _, model := range models {
+		wg.Add(1)
+		go func(model string) {
+			defer wg.Done()
+			select {
+			case sem <- struct{}{}:
+				defer func() { <-sem }()
+			case <-ctx.Done():
+				mu.Lock()
+				errs = append(errs, fmt.Errorf("%s: %w", model, ctx.Err()))
+				mu.Unlock()
+				return
+			}
+
+			start := time.Now()
+			resp, err := c.Generate(ctx, GenerateRequest{Model: model})
+			elapsed := time.Since(start)
+
+			mu.Lock()
+			defer mu.Unlock()
+			if err != nil {
+				errs = append(errs, fmt.Errorf("%s: %w", model, err))
+				return
+			}
+			// Prefer the server's measurement, which excludes queueing
+			if resp.LoadDuration > 0 {
+				elapsed = time.Duration(resp.LoadDuration)
+			}
+			durations[model] = elapsed
+		}(model)
+	}
+	wg.Wait()
+
+	return durations, errors.Join(errs...)
+}
+
+// generateNConfig holds the settings of a GenerateN call
+type generateNConfig struct {
+	seeded bool
+	seed   int
+}
+
+// GenerateNOption configures a GenerateN call
+type GenerateNOption func(*generateNConfig)
+
+// WithSeedSequence gives the samples of GenerateN the seeds start,
+// start+1, and so on, so the batch is diverse yet reproducible
+func WithSeedSequence(start int) GenerateNOption {
+	return func(cfg *generateNConfig) {
+		cfg.seeded = true
+		cfg.seed = start
+	}
+}
+
+// GenerateN generates n samples for the same request, one after another.
+// Without WithSeedSequence every sample uses the request's own options. On
+// error the samples generated so far are returned. A negative n is a
+// RequestError.
+func (c *Client) GenerateN(ctx context.Context, req GenerateRequest, n int, opts ...GenerateNOption) ([]*GenerateResponse, error) {
+	if n < 0 {
+		return nil, &RequestError{Message: fmt.Sprintf("sample count %d is negative", n)}
+	}
+	var cfg generateNConfig
+	for _, opt := range opts {
+		opt(&cfg)
+	}
+
+	responses := make([]*GenerateResponse, 0, n)
+	for i := 0; i < n; i++ {
+		sample := req
+		if cfg.seeded {
+			options := &Options{}
+			if req.Options != nil {
+				options = req.Options.clone()
+			}
+			options.Seed = ptr(cfg.seed + i)
+			sample.Options = options
+		}
+
+		resp, err := c.Generate(ctx, sample)
+		if err != nil {
+			return responses, fmt.Errorf("sample %d: %w", i, err)
+		}
+		responses = append(responses, resp)
+	}
+	return responses, nil
+}
+
+// GenerateResult is the outcome of one request in a GenerateBatch
+type GenerateResult struct {
+	Response *GenerateResponse
+	Err      error
+}
+
+// GenerateBatch runs independent generate requests with at most concurrency
+// in flight and returns their results in input order. Once ctx is done no
+// new requests start, requests in flight finish with the context's error,
+// and the requests never started get it as their Err; the returned error is
+// then ctx.Err(). Failures of single requests are only reported in their
+// results.
+func (c *Client) GenerateBatch(ctx context.Context, reqs []GenerateRequest, concurrency int) ([]GenerateResult, error) {
+	if concurrency < 1 {
+		concurrency = 1
+	}
+
+	results := make([]GenerateResult, len(reqs))
+	work := make(chan int)
+	var wg sync.WaitGroup
+	for w := 0; w < concurrency && w < len(reqs); w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range work {
+				resp, err := c.Generate(ctx, reqs[i])
+				results[i] = GenerateResult{Response: resp, Err: err}
+			}
+		}()
+	}
+
+	next := 0
+schedule:
+	for ; next < len(reqs); next++ {
+		select {
+		case work <- next:
+		case <-ctx.Done():
+			break schedule
+		}
+	}
+	close(work)
+	wg.Wait()
+
+	if next < len(reqs) {
+		for i := next; i < len(reqs); i++ {
+			results[i].Err = ctx.Err()
+		}
+		return results, ctx.Err()
+	}
+	return results, nil
+}
+
+// PullModels pulls several models, at most concurrency at a time, calling
+// onProgress with each progress update. onProgress may be nil; otherwise it
+// is called from several goroutines at once and must be safe for concurrent
+// use. Models that fail to pull are reported together in the error.
+func (c *Client) PullModels(ctx context.Context, names []string, concurrency int, onProgress func(name string, p ProgressResponse)) error {
+	if concurrency < 1 {
+		concurrency = 1
+	}
+
+	var (
+		mu   sync.Mutex
+		wg   sync.WaitGroup
+		errs []error
+		sem  = make(chan struct{}, concurrency)
+	)
+	fail := func(name string, err error) {
+		mu.Lock()
+		errs = append(errs, fmt.Errorf("%s: %w", name, err))
+		mu.Unlock()
+	}
+	for _, name := range names {
+		wg.Add(1)
+		go func(name string) {
+			defer wg.Done()
+			select {
+			case sem <- struct{}{}:
+				defer func() { <-sem }()
+			case <-ctx.Done():
+				fail(name, ctx.Err())
+				return
+			}
+
+			respChan, errChan := c.PullModelStream(ctx, PullModelRequest{Name: name})
+			var pullErr error
+			for p := range respChan {
+				if p.Error != "" && pullErr == nil {
+					pullErr = errors.New(p.Error)
+				}
+				if onProgress != nil {
+					onProgress(name, p)
+				}
+			}
+			if err := <-errChan; err != nil {
+				pullErr = err
+			}
+			if pullErr != nil {
+				fail(name, pullErr)
+			}
+		}(name)
+	}
+	wg.Wait()
+
+	return errors.Join(errs...)
+}
@@ -0,0 +1,166 @@
+// batch_test.go
+package ollamago
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestWarmupAll(t *testing.T) {
+	var (
+		mu             sync.Mutex
+		inFlight, peak int
+		loadDurations  = map[string]time.Duration{"a": time.Second, "b": 2 * time.Second, "c": 3 * time.Second}
+	)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req GenerateRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			t.Errorf("decoding request: %v", err)
+		}
+		mu.Lock()
+		inFlight++
+		peak = max(peak, inFlight)
+		mu.Unlock()
+		time.Sleep(20 * time.Millisecond)
+		mu.Lock()
+		inFlight--
+		mu.Unlock()
+
+		w.Header().Set("Content-Type", "application/json")
+		d, ok := loadDurations[req.Model]
+		if !ok {
+			w.WriteHeader(http.StatusNotFound)
+			fmt.Fprintf(w, `{"error":"model '%s' not found"}`, req.Model)
+			return
+		}
+		fmt.Fprintf(w, `{"done":true,"done_reason":"load","load_duration":%d}`, d)
+	}))
+	defer srv.Close()
+	c := NewClient(WithBaseURL(srv.URL))
+
+	durations, err := c.WarmupAll(context.Background(), []string{"a", "b", "missing", "c"}, 2)
+	if !errors.Is(err, ErrModelNotFound) {
+		t.Errorf("err = %v, want the missing model's ErrModelNotFound", err)
+	}
+	if len(durations) != 3 {
+		t.Errorf("durations = %v, want a, b and c", durations)
+	}
+	for model, want := range loadDurations {
+		if durations[model] != want {
+			t.Errorf("%s loaded in %v, want the server's %v", model, durations[model], want)
+		}
+	}
+	if peak > 2 {
+		t.Errorf("%d models loaded at once, want at most 2", peak)
+	}
+}
+
+func TestSeedSequence(t *testing.T) {
+	var seeds []int
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req GenerateRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			t.Errorf("decoding request: %v", err)
+		}
+		seed := -1
+		if req.Options != nil && req.Options.Seed != nil {
+			seed = *req.Options.Seed
+		}
+		seeds = append(seeds, seed)
+		w.Header().Set("Content-Type", "application/json")
+		fmt.Fprint(w, `{"response":"ok","done":true}`)
+	}))
+	defer srv.Close()
+	c := NewClient(WithBaseURL(srv.URL))
+
+	opts := &Options{Temperature: ptr(0.9), Seed: ptr(7)}
+	req := GenerateRequest{Model: "m", Prompt: "hi", Options: opts}
+	responses, err := c.GenerateN(context.Background(), req, 3, WithSeedSequence(100))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(responses) != 3 {
+		t.Errorf("%d responses, want 3", len(responses))
+	}
+	if want := []int{100, 101, 102}; !slices.Equal(seeds, want) {
+		t.Errorf("seeds = %v, want %v", seeds, want)
+	}
+	if *opts.Seed != 7 || *opts.Temperature != 0.9 {
+		t.Errorf("caller's options changed to %+v", opts)
+	}
+
+	// Without a sequence every sample keeps the request's seed
+	seeds = nil
+	if _, err := c.GenerateN(context.Background(), req, 2); err != nil {
+		t.Fatal(err)
+	}
+	if want := []int{7, 7}; !slices.Equal(seeds, want) {
+		t.Errorf("seeds = %v, want %v", seeds, want)
+	}
+}
+
+func TestPullModels(t *testing.T) {
+	var (
+		mu             sync.Mutex
+		inFlight, peak int
+	)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req PullModelRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			t.Errorf("decoding request: %v", err)
+		}
+		mu.Lock()
+		inFlight++
+		peak = max(peak, inFlight)
+		mu.Unlock()
+		defer func() {
+			mu.Lock()
+			inFlight--
+			mu.Unlock()
+		}()
+
+		w.Header().Set("Content-Type", "application/x-ndjson")
+		fmt.Fprintln(w, `{"status":"pulling manifest"}`)
+		w.(http.Flusher).Flush()
+		time.Sleep(20 * time.Millisecond)
+		if req.Name == "bad" {
+			fmt.Fprintln(w, `{"error":"pull model manifest: file does not exist"}`)
+			return
+		}
+		fmt.Fprintln(w, `{"status":"success"}`)
+	}))
+	defer srv.Close()
+	c := NewClient(WithBaseURL(srv.URL))
+
+	var progressMu sync.Mutex
+	statuses := map[string][]string{}
+	err := c.PullModels(context.Background(), []string{"a", "b", "bad", "c"}, 2, func(name string, p ProgressResponse) {
+		progressMu.Lock()
+		defer progressMu.Unlock()
+		statuses[name] = append(statuses[name], p.Status)
+	})
+
+	if err == nil || !strings.Contains(err.Error(), "bad: ") || !strings.Contains(err.Error(), "file does not exist") {
+		t.Errorf("err = %v, want the bad model's failure", err)
+	}
+	for _, name := range []string{"a", "b", "c"} {
+		if want := []string{"pulling manifest", "success"}; !slices.Equal(statuses[name], want) {
+			t.Errorf("%s progress = %v, want %v", name, statuses[name], want)
+		}
+		if err != nil && strings.Contains(err.Error(), name+": ") {
+			t.Errorf("err = %v, reports %s which succeeded", err, name)
+		}
+	}
+	if peak > 2 {
+		t.Errorf("%d pulls at once, want at most 2", peak)
+	}
+}
@@ -0,0 +1,158 @@
+// blob.go
+package ollamago
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+)
+
+// CheckBlob reports whether the server already has the blob with the given
+// digest, in the form "sha256:<hex>"
+func (c *Client) CheckBlob(ctx context.Context, digest string) (bool, error) {
+	if digest == "" {
+		return false, &RequestError{Message: "digest is required"}
+	}
+
+	resp, err := c.send(ctx, http.MethodHead, "/api/blobs/"+digest, nil, "")
+	if err != nil {
+		return false, err
+	}
+	defer resp.Body.Close()
+
+	switch resp.StatusCode {
+	case http.StatusOK:
+		return true, nil
+	case http.StatusNotFound:
+		return false, nil
+	default:
+		return false, &ResponseError{StatusCode: resp.StatusCode, Message: http.StatusText(resp.StatusCode), Header: resp.Header}
+	}
+}
+
+// CreateBlob uploads the contents of r as the blob with the given digest.
+// The server rejects the upload when the contents do not match the digest.
+func (c *Client) CreateBlob(ctx context.Context, digest string, r io.Reader) error {
+	if digest == "" {
+		return &RequestError{Message: "digest is required"}
+	}
+
+	resp, err := c.send(ctx, http.MethodPost, "/api/blobs/"+digest, r, "application/octet-stream")
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK && resp.StatusCode != http.StatusCreated {
+		return c.responseError(resp)
+	}
+	return nil
+}
+
+// fileDigest returns the sha256 digest of a file in the form "sha256:<hex>"
+func fileDigest(path string) (string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+
+	h := sha256.New()
+	if _, err := io.Copy(h, f); err != nil {
+		return "", err
+	}
+	return "sha256:" + hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// uploadFile uploads a local file as a blob unless the server already has
+// it, returning the blob digest
+func (c *Client) uploadFile(ctx context.Context, path string) (string, error) {
+	digest, err := fileDigest(path)
+	if err != nil {
+		return "", fmt.Errorf("hashing %s: %w", path, err)
+	}
+
+	exists, err := c.CheckBlob(ctx, digest)
+	if err != nil {
+		return "", fmt.Errorf("checking blob for %s: %w", path, err)
+	}
+	if exists {
+		return digest, nil
+	}
+
+	f, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+
+	if err := c.CreateBlob(ctx, digest, f); err != nil {
+		return "", fmt.Errorf("uploading %s: %w", path, err)
+	}
+	return digest, nil
+}
+
+// modelfileFromBlob points the FROM instruction of a Modelfile at an
+// uploaded blob. FROM lines naming path are rewritten, and a FROM line is
+// added when the Modelfile has none.
+func modelfileFromBlob(modelfile, path, digest string) string {
+	from := "FROM @" + digest
+	lines := strings.Split(modelfile, "\n")
+	found := false
+	for i, line := range lines {
+		fields := strings.Fields(line)
+		if len(fields) < 2 || !strings.EqualFold(fields[0], "FROM") {
+			continue
+		}
+		found = true
+		if fields[1] == path {
+			lines[i] = from
+		}
+	}
+	if !found {
+		return strings.TrimRight(from+"\n"+modelfile, "\n")
+	}
+	return strings.Join(lines, "\n")
+}
+
+// CreateFromFiles creates a model from local files, such as GGUF weights
+// and adapters. files maps the file name the server should see to a local
+// path. Every file is uploaded as a blob unless the server already has it,
+// then the model is created and onProgress, if not nil, receives each
+// creation progress update.
+func (c *Client) CreateFromFiles(ctx context.Context, name string, files map[string]string, onProgress func(ProgressResponse)) error {
+	if name == "" {
+		return &RequestError{Message: "model name is required"}
+	}
+	if len(files) == 0 {
+		return &RequestError{Message: "at least one file is required"}
+	}
+
+	digests := make(map[string]string, len(files))
+	var errs []error
+	for file, path := range files {
+		digest, err := c.uploadFile(ctx, path)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", file, err))
+			continue
+		}
+		digests[file] = digest
+	}
+	if err := errors.Join(errs...); err != nil {
+		return err
+	}
+
+	respChan, errChan := c.CreateModelStream(ctx, CreateModelRequest{Model: name, Name: name, Files: digests})
+	for progress := range respChan {
+		if onProgress != nil {
+			onProgress(progress)
+		}
+	}
+	return <-errChan
+}
@@ -0,0 +1,109 @@
+// blob_test.go
+package ollamago
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+)
+
+func TestCreateFromFiles(t *testing.T) {
+	dir := t.TempDir()
+	weights := filepath.Join(dir, "model.gguf")
+	adapter := filepath.Join(dir, "adapter.gguf")
+	if err := os.WriteFile(weights, []byte("weights"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(adapter, []byte("adapter"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	digest := func(data string) string {
+		sum := sha256.Sum256([]byte(data))
+		return "sha256:" + hex.EncodeToString(sum[:])
+	}
+
+	var (
+		mu       sync.Mutex
+		uploaded = map[string]string{}
+		created  CreateModelRequest
+	)
+	// The server already has the weights
+	stored := map[string]bool{digest("weights"): true}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+		switch {
+		case strings.HasPrefix(r.URL.Path, "/api/blobs/"):
+			d := strings.TrimPrefix(r.URL.Path, "/api/blobs/")
+			if r.Method == http.MethodHead {
+				if !stored[d] {
+					w.WriteHeader(http.StatusNotFound)
+				}
+				return
+			}
+			body, _ := io.ReadAll(r.Body)
+			uploaded[d] = string(body)
+			stored[d] = true
+			w.WriteHeader(http.StatusCreated)
+		case r.URL.Path == "/api/create":
+			if err := json.NewDecoder(r.Body).Decode(&created); err != nil {
+				t.Errorf("decoding request: %v", err)
+			}
+			w.Header().Set("Content-Type", "application/x-ndjson")
+			fmt.Fprintln(w, `{"status":"parsing GGUF"}`)
+			fmt.Fprintln(w, `{"status":"success"}`)
+		}
+	}))
+	defer srv.Close()
+	c := NewClient(WithBaseURL(srv.URL))
+
+	var statuses []string
+	err := c.CreateFromFiles(context.Background(), "custom", map[string]string{
+		"model.gguf":   weights,
+		"adapter.gguf": adapter,
+	}, func(p ProgressResponse) { statuses = append(statuses, p.Status) })
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if want := map[string]string{digest("adapter"): "adapter"}; !reflect.DeepEqual(uploaded, want) {
+		t.Errorf("uploaded = %v, want only the adapter", uploaded)
+	}
+	wantFiles := map[string]string{"model.gguf": digest("weights"), "adapter.gguf": digest("adapter")}
+	if created.Model != "custom" || !reflect.DeepEqual(created.Files, wantFiles) {
+		t.Errorf("create request = %+v, want files %v", created, wantFiles)
+	}
+	if want := []string{"parsing GGUF", "success"}; !reflect.DeepEqual(statuses, want) {
+		t.Errorf("progress = %v, want %v", statuses, want)
+	}
+}
+
+func TestCheckBlobError(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Retry-After", "7")
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer srv.Close()
+	c := NewClient(WithBaseURL(srv.URL))
+
+	_, err := c.CheckBlob(context.Background(), "sha256:abc")
+	var respErr *ResponseError
+	if !errors.As(err, &respErr) {
+		t.Fatalf("err = %v, want a ResponseError", err)
+	}
+	if d, ok := respErr.RetryAfter(); !ok || d.Seconds() != 7 {
+		t.Errorf("RetryAfter = %v, %v; want the response header", d, ok)
+	}
+}
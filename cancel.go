@@ -0,0 +1,47 @@
+// cancel.go
+package ollamago
+
+import (
+	"context"
+)
+
+// CancelGroup cancels a set of in-flight requests at once, for example when
+// a user navigates away from a page that started several generations
+type CancelGroup struct {
+	ctx    context.Context
+	cancel context.CancelFunc
+}
+
+// NewCancelGroup creates an empty cancel group
+func NewCancelGroup() *CancelGroup {
+	ctx, cancel := context.WithCancel(context.Background())
+	return &CancelGroup{ctx: ctx, cancel: cancel}
+}
+
+// Context derives a request context from parent that is also cancelled when
+// the group is cancelled. The returned cancel function releases the context
+// from the group and should be called once the request completes.
+func (g *CancelGroup) Context(parent context.Context) (context.Context, context.CancelFunc) {
+	ctx, cancel := context.WithCancel(parent)
+	if g.ctx.Err() != nil {
+		// AfterFunc would cancel asynchronously
+		cancel()
+		return ctx, cancel
+	}
+	stop := context.AfterFunc(g.ctx, cancel)
+	return ctx, func() {
+		stop()
+		cancel()
+	}
+}
+
+// Cancel cancels every context derived from the group. Contexts derived after
+// Cancel are cancelled immediately.
+func (g *CancelGroup) Cancel() {
+	g.cancel()
+}
+
+// Done returns a channel that is closed once the group is cancelled
+func (g *CancelGroup) Done() <-chan struct{} {
+	return g.ctx.Done()
+}
@@ -0,0 +1,177 @@
+// chunk.go
+package ollamago
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"unicode"
+)
+
+// Chunk is a piece of a document, with its embedding once computed
+type Chunk struct {
+	// Index is the position of the chunk in the document
+	Index int
+	Text  string
+	// Start and End are the byte offsets of Text in the document
+	Start, End int
+	Embedding  []float64
+}
+
+// embedDocumentConcurrency is the number of chunks EmbedDocumentStream
+// embeds at once
+const embedDocumentConcurrency = 4
+
+// wordSpan is the byte range of a word in a text
+type wordSpan struct {
+	start, end int
+}
+
+// splitWords returns the byte ranges of the whitespace separated words of
+// text
+func splitWords(text string) []wordSpan {
+	var words []wordSpan
+	start := -1
+	for i, r := range text {
+		switch {
+		case unicode.IsSpace(r) && start >= 0:
+			words = append(words, wordSpan{start, i})
+			start = -1
+		case !unicode.IsSpace(r) && start < 0:
+			start = i
+		}
+	}
+	if start >= 0 {
+		words = append(words, wordSpan{start, len(text)})
+	}
+	return words
+}
+
+// ChunkText splits text into chunks of at most chunkTokens tokens, by the
+// HeuristicCounter estimate, each repeating up to overlap tokens from the
+// end of the previous one. Chunks break between words and keep the text's
+// own whitespace; a single word longer than chunkTokens is a chunk of its
+// own.
+func ChunkText(text string, chunkTokens, overlap int) ([]Chunk, error) {
+	if chunkTokens < 1 {
+		return nil, &RequestError{Message: "chunk size must be positive"}
+	}
+	if overlap < 0 || overlap >= chunkTokens {
+		return nil, &RequestError{Message: fmt.Sprintf("overlap must be between 0 and %d", chunkTokens-1)}
+	}
+
+	words := splitWords(text)
+	tokens := make([]int, len(words))
+	for i, w := range words {
+		tokens[i] = HeuristicCounter{}.Count(text[w.start:w.end])
+	}
+
+	var chunks []Chunk
+	for i := 0; i < len(words); {
+		j, size := i, 0
+		for j < len(words) && (j == i || size+tokens[j] <= chunkTokens) {
+			size += tokens[j]
+			j++
+		}
+		start, end := words[i].start, words[j-1].end
+		chunks = append(chunks, Chunk{Index: len(chunks), Text: text[start:end], Start: start, End: end})
+		if j == len(words) {
+			break
+		}
+
+		// Step back over the overlap, always moving forward by a word
+		k, repeated := j, 0
+		for k > i+1 && repeated+tokens[k-1] <= overlap {
+			repeated += tokens[k-1]
+			k--
+		}
+		i = k
+	}
+	return chunks, nil
+}
+
+// EmbedDocumentStream splits text with ChunkText and embeds the chunks,
+// several at a time, sending each on the first channel as soon as it and
+// all chunks before it are embedded, so chunks arrive in document order.
+// The first failure ends the stream. See GenerateStream for how to consume
+// the channels.
+func (c *Client) EmbedDocumentStream(ctx context.Context, model, text string, chunkTokens, overlap int) (<-chan Chunk, <-chan error) {
+	out := make(chan Chunk)
+	errChan := make(chan error, 1)
+
+	go func() {
+		// Close the error channel last so it holds the terminal error, if
+		// any, by the time the chunk channel is closed
+		defer close(errChan)
+		defer close(out)
+
+		if model == "" {
+			errChan <- &RequestError{Message: "model is required"}
+			return
+		}
+		chunks, err := ChunkText(text, chunkTokens, overlap)
+		if err != nil {
+			errChan <- err
+			return
+		}
+
+		var wg sync.WaitGroup
+		defer wg.Wait()
+		workCtx, cancel := context.WithCancel(ctx)
+		defer cancel()
+
+		results := make([]chan error, len(chunks))
+		for i := range results {
+			results[i] = make(chan error, 1)
+		}
+		work := make(chan int)
+		for w := 0; w < embedDocumentConcurrency && w < len(chunks); w++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				for i := range work {
+					resp, err := c.Embed(workCtx, EmbedRequest{Model: model, Input: chunks[i].Text})
+					if err == nil {
+						chunks[i].Embedding = resp.Embeddings[0]
+					}
+					results[i] <- err
+				}
+			}()
+		}
+		go func() {
+			defer close(work)
+			for i := range chunks {
+				select {
+				case work <- i:
+				case <-workCtx.Done():
+					return
+				}
+			}
+		}()
+
+		for i := range chunks {
+			select {
+			case err := <-results[i]:
+				if err != nil {
+					if ctx.Err() != nil {
+						err = context.Cause(ctx)
+					}
+					errChan <- fmt.Errorf("embedding chunk %d: %w", i, err)
+					return
+				}
+			case <-ctx.Done():
+				errChan <- context.Cause(ctx)
+				return
+			}
+
+			select {
+			case out <- chunks[i]:
+			case <-ctx.Done():
+				errChan <- context.Cause(ctx)
+				return
+			}
+		}
+	}()
+
+	return out, errChan
+}
@@ -0,0 +1,116 @@
+// chunk_test.go
+package ollamago
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestChunkText(t *testing.T) {
+	tests := []struct {
+		name        string
+		text        string
+		chunkTokens int
+		overlap     int
+		want        []string
+	}{
+		{"empty", "", 3, 0, nil},
+		{"single chunk", "a b c", 3, 0, []string{"a b c"}},
+		{"no overlap", "a b c d e f g", 3, 0, []string{"a b c", "d e f", "g"}},
+		{"overlap", "a b c d e f g", 3, 1, []string{"a b c", "c d e", "e f g"}},
+		{"largest overlap", "a b c d", 3, 2, []string{"a b c", "b c d"}},
+		{"keeps whitespace", "a\n\nb  c d", 3, 0, []string{"a\n\nb  c", "d"}},
+		{"long word", "a abcdefghijklmnop b", 3, 1, []string{"a", "abcdefghijklmnop", "b"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			chunks, err := ChunkText(tt.text, tt.chunkTokens, tt.overlap)
+			if err != nil {
+				t.Fatalf("ChunkText: %v", err)
+			}
+			var got []string
+			for i, chunk := range chunks {
+				if chunk.Index != i {
+					t.Errorf("chunk %d has index %d", i, chunk.Index)
+				}
+				if tt.text[chunk.Start:chunk.End] != chunk.Text {
+					t.Errorf("chunk %d offsets %d:%d do not match its text %q", i, chunk.Start, chunk.End, chunk.Text)
+				}
+				got = append(got, chunk.Text)
+			}
+			if !slices.Equal(got, tt.want) {
+				t.Errorf("chunks = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestChunkTextInvalid(t *testing.T) {
+	tests := []struct {
+		name                 string
+		chunkTokens, overlap int
+	}{
+		{"zero size", 0, 0},
+		{"negative overlap", 3, -1},
+		{"overlap equals size", 3, 3},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := ChunkText("a b c", tt.chunkTokens, tt.overlap)
+			var reqErr *RequestError
+			if !errors.As(err, &reqErr) {
+				t.Errorf("err = %v, want a RequestError", err)
+			}
+		})
+	}
+}
+
+func TestEmbedDocumentStreamOrder(t *testing.T) {
+	const words = 10
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req struct {
+			Input string `json:"input"`
+		}
+		json.NewDecoder(r.Body).Decode(&req)
+		n, _ := strconv.Atoi(strings.TrimPrefix(req.Input, "w"))
+		// Later chunks finish first
+		time.Sleep(time.Duration(words-n) * 2 * time.Millisecond)
+		w.Header().Set("Content-Type", "application/json")
+		fmt.Fprintf(w, `{"embeddings":[[%d]]}`, n)
+	}))
+	defer srv.Close()
+
+	var text []string
+	for i := 0; i < words; i++ {
+		text = append(text, fmt.Sprintf("w%d", i))
+	}
+
+	c := NewClient(WithBaseURL(srv.URL))
+	chunks, errs := c.EmbedDocumentStream(context.Background(), "m", strings.Join(text, " "), 1, 0)
+	var got []int
+	for chunk := range chunks {
+		if len(chunk.Embedding) != 1 || int(chunk.Embedding[0]) != chunk.Index {
+			t.Errorf("chunk %d has embedding %v", chunk.Index, chunk.Embedding)
+		}
+		got = append(got, chunk.Index)
+	}
+	if err := <-errs; err != nil {
+		t.Fatalf("stream error: %v", err)
+	}
+	want := make([]int, words)
+	for i := range want {
+		want[i] = i
+	}
+	if !slices.Equal(got, want) {
+		t.Errorf("emitted indices = %v, want %v", got, want)
+	}
+}
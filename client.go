@@ -7,21 +7,67 @@ import (
 	"encoding/json"
 	"fmt"
 	"io"
+	"log"
+	"net"
 	"net/http"
 	"net/url"
 	"os"
 	"runtime"
 	"strings"
+	"sync"
 	"time"
 )
 
 // Client represents an Ollama API client
 type Client struct {
-	baseURL    string
-	httpClient *http.Client
-	headers    http.Header
+	baseURL      string
+	httpClient   *http.Client
+	headers      http.Header
+	echoRequest  bool
+	errOnEmpty   bool
+	queue        *priorityQueue
+	maxStop      int
+	assertions   bool
+	debugLog     *log.Logger
+	returnPrompt bool
+	streamBuf    int
+	retry        *retryPolicy
+	authFunc     func(*http.Request) error
+	compat       VersionCompatPolicy
+	tokenCounter TokenCounter
+	maxPrompt    int64
+
+	validateOptions    bool
+	validateEmbeddings bool
+	firstByteTimeout   time.Duration
+	idleTimeout        time.Duration
+	trailingWait       time.Duration
+	autoTrim           bool
+	requestTimeout     time.Duration
+	keepAlive          string
+	meter              *TokenMeter
+	decoderOpts        []func(*json.Decoder)
+	ownTransport       *http.Transport
+	trimStrategy       TrimStrategy
+	usage              *UsageTracker
+	limiter            *rateLimiter
+
+	requestHooks  []func(*http.Request)
+	bodyHook      func(endpoint string, body []byte)
+	responseHooks []func(*http.Response, time.Duration)
+
+	templatesMu sync.Mutex
+	templates   map[string]*chatTemplate
+
+	versionMu sync.Mutex
+	version   string
 }
 
+// DefaultStreamBufferSize is the default maximum size of a single line of a
+// server-sent event stream. Final frames can be large when they carry a long
+// context array.
+const DefaultStreamBufferSize = 1 << 20
+
 // Option is a function that configures the client
 type Option func(*Client)
 
@@ -29,10 +75,19 @@ type Option func(*Client)
 func NewClient(options ...Option) *Client {
 	c := &Client{
 		baseURL: parseHost(os.Getenv("OLLAMA_HOST")),
-		httpClient: &http.Client{
-			Timeout: time.Second * 30,
-		},
-		headers: make(http.Header),
+		// Calls are bounded by their context rather than a client timeout,
+		// which would also fire during long generations
+		httpClient: &http.Client{},
+
+		headers:   make(http.Header),
+		templates: make(map[string]*chatTemplate),
+		maxStop:   DefaultMaxStopSequences,
+		streamBuf: DefaultStreamBufferSize,
+		maxPrompt: DefaultMaxPromptSize,
+
+		trailingWait: DefaultTrailingFrameWait,
+
+		validateOptions: true,
 	}
 
 	// Set default headers
@@ -70,27 +125,237 @@ func WithHeader(key, value string) Option {
 	}
 }
 
-// WithTimeout sets the HTTP client timeout
+// WithTimeout sets a default timeout for non-streaming calls whose context
+// has no deadline. It is the same as WithRequestTimeout.
 func WithTimeout(timeout time.Duration) Option {
+	return WithRequestTimeout(timeout)
+}
+
+// WithRequestTimeout sets a default timeout for non-streaming calls whose
+// context has no deadline. Calls with a deadline keep their own.
+func WithRequestTimeout(timeout time.Duration) Option {
 	return func(c *Client) {
-		c.httpClient.Timeout = timeout
+		c.requestTimeout = timeout
 	}
 }
 
-// request makes an HTTP request to the Ollama API
-func (c *Client) request(ctx context.Context, method, path string, body interface{}, response interface{}, stream bool) error {
-	var bodyReader io.Reader
+// WithKeepAlive sets how long models stay loaded after generate, chat and
+// embed requests that do not set their own KeepAlive. A negative duration
+// keeps them loaded indefinitely and zero unloads them right away.
+func WithKeepAlive(d time.Duration) Option {
+	return func(c *Client) {
+		c.keepAlive = d.String()
+	}
+}
+
+// applyKeepAlive fills in the default keep alive when a request has none
+func (c *Client) applyKeepAlive(keepAlive *string) {
+	if *keepAlive == "" {
+		*keepAlive = c.keepAlive
+	}
+}
+
+// WithEchoRequest attaches the request that was sent to Generate and Chat
+// responses, which helps correlate logged responses with their inputs
+func WithEchoRequest(enabled bool) Option {
+	return func(c *Client) {
+		c.echoRequest = enabled
+	}
+}
+
+// WithErrorOnEmptyResponse makes Generate and Chat return ErrEmptyResponse
+// when the model finishes without producing any output
+func WithErrorOnEmptyResponse(enabled bool) Option {
+	return func(c *Client) {
+		c.errOnEmpty = enabled
+	}
+}
+
+// WithPriorityQueue limits the client to maxInFlight concurrent requests and
+// dispatches queued requests by priority, so interactive calls are not stuck
+// behind batch work. Set a request's priority with ContextWithPriority.
+func WithPriorityQueue(maxInFlight int) Option {
+	return func(c *Client) {
+		if maxInFlight < 1 {
+			maxInFlight = 1
+		}
+		c.queue = newPriorityQueue(maxInFlight)
+	}
+}
+
+// WithStreamAssertions makes GenerateStream and ChatStream verify that the
+// server follows the streaming protocol: exactly one done frame, nothing
+// after it, and token counts that never decrease. Violations are reported
+// as ErrStreamViolation. Meant for tests and debugging proxies.
+func WithStreamAssertions(enabled bool) Option {
+	return func(c *Client) {
+		c.assertions = enabled
+	}
+}
+
+// WithDebugLogger logs the body of every failed API response to logger.
+// Response bodies may contain prompt fragments, so this is off by default.
+func WithDebugLogger(logger *log.Logger) Option {
+	return func(c *Client) {
+		c.debugLog = logger
+	}
+}
+
+// WithReturnPrompt sets the Prompt field of Generate and Chat responses to
+// the templated prompt the model received, reconstructed from the model's
+// template. Useful for debugging prompt formatting.
+func WithReturnPrompt(enabled bool) Option {
+	return func(c *Client) {
+		c.returnPrompt = enabled
+	}
+}
+
+// WithStreamBufferSize sets the maximum size of a single line in a streamed
+// response wrapped in server-sent events by a proxy. Plain NDJSON streams
+// are decoded without a line limit.
+func WithStreamBufferSize(size int) Option {
+	return func(c *Client) {
+		if size > 0 {
+			c.streamBuf = size
+		}
+	}
+}
+
+// WithMaxPromptSize sets the maximum number of bytes GenerateFromReader
+// reads from its reader
+func WithMaxPromptSize(size int64) Option {
+	return func(c *Client) {
+		if size > 0 {
+			c.maxPrompt = size
+		}
+	}
+}
+
+// WithRetry retries requests without side effects up to maxAttempts times
+// in total when they fail with a network error, a 5xx status or a 429.
+// Attempts are spaced by exponential backoff with jitter starting at
+// backoff, or by the server's Retry-After when longer, and stop early when
+// the context deadline would pass. Streaming requests are
+// only retried before any of the response is received.
+func WithRetry(maxAttempts int, backoff time.Duration) Option {
+	return func(c *Client) {
+		c.retry = &retryPolicy{maxAttempts: maxAttempts, backoff: backoff}
+	}
+}
+
+// WithRequestInterceptor calls fn with every HTTP request just before it is
+// sent, including each retry attempt, for logging and tracing. fn may add
+// headers but must not consume the body. Interceptors run in the order they
+// were added.
+func WithRequestInterceptor(fn func(*http.Request)) Option {
+	return func(c *Client) {
+		c.requestHooks = append(c.requestHooks, fn)
+	}
+}
+
+// WithResponseInterceptor calls fn with every HTTP response and the time it
+// took to arrive. For streaming calls the response arrives with its headers,
+// before the body is read, so fn must not consume the body.
+func WithResponseInterceptor(fn func(*http.Response, time.Duration)) Option {
+	return func(c *Client) {
+		c.responseHooks = append(c.responseHooks, fn)
+	}
+}
+
+// WithRequestBodyHook calls fn with the endpoint path and the exact JSON
+// body of every API request before it is sent, for audit logging. It runs
+// once per call, not per retry, and not for blob uploads. fn must not
+// modify or retain body.
+func WithRequestBodyHook(fn func(endpoint string, body []byte)) Option {
+	return func(c *Client) {
+		c.bodyHook = fn
+	}
+}
+
+// WithDecoderOptions configures the JSON decoder of every API response, for
+// example with UseNumber so numbers in free-form fields such as ModelInfo
+// keep their exact text, or DisallowUnknownFields to notice API changes.
+// The same options apply to DecodeJSON.
+func WithDecoderOptions(opts ...func(*json.Decoder)) Option {
+	return func(c *Client) {
+		c.decoderOpts = append(c.decoderOpts, opts...)
+	}
+}
+
+// newDecoder returns a JSON decoder with the client's decoder options
+func (c *Client) newDecoder(r io.Reader) *json.Decoder {
+	dec := json.NewDecoder(r)
+	for _, opt := range c.decoderOpts {
+		opt(dec)
+	}
+	return dec
+}
+
+// DecodeJSON decodes JSON text produced by a model, such as the Response of
+// a request with FormatSchema, using the client's decoder options
+func (c *Client) DecodeJSON(text string, v interface{}) error {
+	return c.newDecoder(strings.NewReader(text)).Decode(v)
+}
+
+// do builds and sends an HTTP request to the Ollama API, retrying transient
+// failures when the client was created with WithRetry
+func (c *Client) do(ctx context.Context, method, path string, body interface{}) (*http.Response, error) {
+	var bodyBytes []byte
 	if body != nil {
-		bodyBytes, err := json.Marshal(body)
+		var err error
+		bodyBytes, err = json.Marshal(body)
 		if err != nil {
-			return fmt.Errorf("marshaling request body: %w", err)
+			return nil, fmt.Errorf("marshaling request body: %w", err)
+		}
+		if c.bodyHook != nil {
+			c.bodyHook(path, bodyBytes)
+		}
+	}
+
+	for attempt := 1; ; attempt++ {
+		var bodyReader io.Reader
+		if bodyBytes != nil {
+			bodyReader = bytes.NewReader(bodyBytes)
+		}
+		resp, err := c.send(ctx, method, path, bodyReader, "")
+		if !c.shouldRetry(ctx, method, path, attempt, resp, err) {
+			return resp, err
+		}
+
+		wait := c.retry.delay(attempt)
+		if resp != nil {
+			if after, ok := parseRetryAfter(resp.Header, time.Now()); ok {
+				if after > maxBackoff {
+					return resp, err
+				}
+				wait = max(wait, after)
+			}
+		}
+		if deadline, ok := ctx.Deadline(); ok && time.Until(deadline) < wait {
+			return resp, err
+		}
+		if resp != nil {
+			// Discard the failed response so the connection can be reused
+			io.Copy(io.Discard, resp.Body)
+			resp.Body.Close()
+		}
+
+		timer := time.NewTimer(wait)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return nil, ctx.Err()
+		case <-timer.C:
 		}
-		bodyReader = bytes.NewReader(bodyBytes)
 	}
+}
 
-	req, err := http.NewRequestWithContext(ctx, method, c.baseURL+path, bodyReader)
+// send makes a single attempt at an HTTP request. A non-empty contentType
+// replaces the default JSON content type.
+func (c *Client) send(ctx context.Context, method, path string, body io.Reader, contentType string) (*http.Response, error) {
+	req, err := http.NewRequestWithContext(ctx, method, c.baseURL+path, body)
 	if err != nil {
-		return fmt.Errorf("creating request: %w", err)
+		return nil, fmt.Errorf("creating request: %w", err)
 	}
 	// Add headers
 	for key, values := range c.headers {
@@ -98,136 +363,205 @@ func (c *Client) request(ctx context.Context, method, path string, body interfac
 			req.Header.Add(key, value)
 		}
 	}
-
-	resp, err := c.httpClient.Do(req)
-	if err != nil {
-		return fmt.Errorf("making request: %w", err)
+	if contentType != "" {
+		req.Header.Set("Content-Type", contentType)
 	}
-	defer resp.Body.Close()
-
-	if resp.StatusCode != http.StatusOK {
-		bodyBytes, err := io.ReadAll(resp.Body)
-		if err != nil {
-			return fmt.Errorf("reading error response: %w", err)
+	if c.authFunc != nil {
+		if err := c.authFunc(req); err != nil {
+			return nil, fmt.Errorf("authenticating request: %w", err)
 		}
-		fmt.Println(string(bodyBytes))
-		// Try to parse error response as JSON
-		var errResp struct {
-			Error string `json:"error"`
+	}
+
+	if c.limiter != nil {
+		if err := c.limiter.wait(ctx); err != nil {
+			return nil, err
 		}
-		if err := json.Unmarshal(bodyBytes, &errResp); err == nil && errResp.Error != "" {
-			return &ResponseError{
-				StatusCode: resp.StatusCode,
-				Message:    errResp.Error,
-			}
+	}
+	if c.queue != nil {
+		if err := c.queue.acquire(ctx, priorityFromContext(ctx)); err != nil {
+			return nil, err
 		}
+	}
 
-		return &ResponseError{
-			StatusCode: resp.StatusCode,
-			Message:    string(bodyBytes),
+	for _, hook := range c.requestHooks {
+		hook(req)
+	}
+
+	httpClient := c.httpClient
+	if ctx.Value(streamingKey{}) != nil && httpClient.Timeout > 0 {
+		// The client timeout covers reading the whole body, which would
+		// end long streams
+		noTimeout := *httpClient
+		noTimeout.Timeout = 0
+		httpClient = &noTimeout
+	}
+
+	start := time.Now()
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		if c.queue != nil {
+			c.queue.release()
 		}
+		return nil, &transportError{err: err}
+	}
+	elapsed := time.Since(start)
+	for _, hook := range c.responseHooks {
+		hook(resp, elapsed)
+	}
+
+	if c.queue != nil {
+		// Hold the slot until the caller is done with the body
+		resp.Body = &releaseOnClose{ReadCloser: resp.Body, release: c.queue.release}
+	}
+
+	return resp, nil
+}
+
+// request makes an HTTP request to the Ollama API
+func (c *Client) request(ctx context.Context, method, path string, body interface{}, response interface{}, stream bool) error {
+	if _, ok := ctx.Deadline(); !ok && c.requestTimeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, c.requestTimeout)
+		defer cancel()
+	}
+
+	resp, err := c.do(ctx, method, path, body)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return c.responseError(resp)
 	}
 
 	if response == nil {
 		return nil
 	}
 
-	if err := json.NewDecoder(resp.Body).Decode(response); err != nil {
+	if err := c.newDecoder(resp.Body).Decode(response); err != nil {
 		return fmt.Errorf("decoding response: %w", err)
 	}
 
 	return nil
 }
 
+// streamingKey marks the context of a streaming request, which is exempt
+// from the HTTP client timeout
+type streamingKey struct{}
+
 // requestStream makes a streaming HTTP request to the Ollama API
 func (c *Client) requestStream(ctx context.Context, method, path string, body interface{}) (*http.Response, error) {
-	var bodyReader io.Reader
-	if body != nil {
-		bodyBytes, err := json.Marshal(body)
-		if err != nil {
-			return nil, fmt.Errorf("marshaling request body: %w", err)
-		}
-		bodyReader = bytes.NewReader(bodyBytes)
+	ctx = context.WithValue(ctx, streamingKey{}, true)
+	resp, err := c.do(ctx, method, path, body)
+	if err != nil {
+		return nil, err
 	}
 
-	req, err := http.NewRequestWithContext(ctx, method, c.baseURL+path, bodyReader)
-	if err != nil {
-		return nil, fmt.Errorf("creating request: %w", err)
+	if resp.StatusCode != http.StatusOK {
+		defer resp.Body.Close()
+		return nil, c.responseError(resp)
 	}
 
-	// Add headers
-	for key, values := range c.headers {
-		for _, value := range values {
-			req.Header.Add(key, value)
-		}
+	// Check if response is JSON or NDJSON stream, possibly wrapped in
+	// server-sent events by a proxy
+	contentType := resp.Header.Get("Content-Type")
+	switch {
+	case strings.Contains(contentType, "application/json"), strings.Contains(contentType, "application/x-ndjson"):
+	case strings.Contains(contentType, "text/event-stream"):
+		resp.Body = newSSEReader(resp.Body, c.streamBuf)
+	default:
+		resp.Body.Close()
+		return nil, fmt.Errorf("unexpected content type: %s", contentType)
 	}
 
-	resp, err := c.httpClient.Do(req)
+	return resp, nil
+}
+
+// responseError reads a non-200 response into a ResponseError
+func (c *Client) responseError(resp *http.Response) error {
+	bodyBytes, err := io.ReadAll(resp.Body)
 	if err != nil {
-		return nil, fmt.Errorf("making request: %w", err)
+		return fmt.Errorf("reading error response: %w", err)
+	}
+	if c.debugLog != nil {
+		c.debugLog.Printf("ollama: %s %s: status %d: %s",
+			resp.Request.Method, resp.Request.URL.Path, resp.StatusCode, bodyBytes)
 	}
 
-	if resp.StatusCode != http.StatusOK {
-		bodyBytes, err := io.ReadAll(resp.Body)
-		resp.Body.Close()
-		if err != nil {
-			return nil, fmt.Errorf("reading error response: %w", err)
-		}
-
-		// Try to parse error response as JSON
-		var errResp struct {
-			Error string `json:"error"`
-		}
-		if err := json.Unmarshal(bodyBytes, &errResp); err == nil && errResp.Error != "" {
-			return nil, &ResponseError{
-				StatusCode: resp.StatusCode,
-				Message:    errResp.Error,
-			}
+	// Try to parse error response as JSON
+	var errResp struct {
+		Error string `json:"error"`
+	}
+	if err := json.Unmarshal(bodyBytes, &errResp); err == nil && errResp.Error != "" {
+		return &ResponseError{
+			StatusCode: resp.StatusCode,
+			Message:    errResp.Error,
+			Header:     resp.Header,
 		}
+	}
 
-		return nil, &ResponseError{
+	// The OpenAI-compatible endpoints nest the message in an object
+	var openAIResp struct {
+		Error struct {
+			Message string `json:"message"`
+		} `json:"error"`
+	}
+	if err := json.Unmarshal(bodyBytes, &openAIResp); err == nil && openAIResp.Error.Message != "" {
+		return &ResponseError{
 			StatusCode: resp.StatusCode,
-			Message:    string(bodyBytes),
+			Message:    openAIResp.Error.Message,
+			Header:     resp.Header,
 		}
 	}
 
-	// Check if response is JSON or NDJSON stream
-	contentType := resp.Header.Get("Content-Type")
-	if !strings.Contains(contentType, "application/json") && !strings.Contains(contentType, "application/x-ndjson") {
-		return nil, fmt.Errorf("unexpected content type: %s", contentType)
+	return &ResponseError{
+		StatusCode: resp.StatusCode,
+		Message:    string(bodyBytes),
+		Header:     resp.Header,
 	}
-
-	return resp, nil
 }
 
-// parseHost parses and validates the host URL
+// defaultPort is the port Ollama listens on by default
+const defaultPort = "11434"
+
+// parseHost turns a host setting such as OLLAMA_HOST into a base URL. The
+// scheme defaults to OLLAMA_SCHEME, or http, and the port to 443 for https
+// and 11434 otherwise. IPv6 addresses may be given with or without
+// brackets. Invalid hosts fall back to the local default.
 func parseHost(host string) string {
-	if host == "" {
-		return "http://127.0.0.1:11434"
+	scheme := "http"
+	if s := os.Getenv("OLLAMA_SCHEME"); s != "" {
+		scheme = strings.ToLower(s)
 	}
+	fallback := scheme + "://" + net.JoinHostPort("127.0.0.1", defaultPort)
 
-	// Add scheme if missing
-	if !strings.HasPrefix(host, "http://") && !strings.HasPrefix(host, "https://") {
-		host = "http://" + host
+	host = strings.TrimSpace(host)
+	if host == "" {
+		return fallback
+	}
+	if !strings.Contains(host, "://") {
+		// A bare IPv6 address has colons that would be taken for a port
+		if ip := net.ParseIP(host); ip != nil && strings.Contains(host, ":") {
+			host = "[" + host + "]"
+		}
+		host = scheme + "://" + host
 	}
 
 	u, err := url.Parse(host)
-	if err != nil {
-		return "http://127.0.0.1:11434"
+	if err != nil || u.Hostname() == "" {
+		return fallback
 	}
 
-	// Add port if missing
-	if u.Port() == "" {
-		switch u.Scheme {
-		case "https":
-			host = fmt.Sprintf("%s:443", host)
-		case "http":
-			if !strings.Contains(host[7:], ":") {
-				host = fmt.Sprintf("%s:11434", host)
-			}
+	port := u.Port()
+	if port == "" {
+		port = defaultPort
+		if u.Scheme == "https" {
+			port = "443"
 		}
 	}
+	u.Host = net.JoinHostPort(u.Hostname(), port)
 
-	// Ensure no trailing slash
-	return strings.TrimSuffix(host, "/")
+	// Keep a path prefix for servers behind a proxy, without a trailing slash
+	return u.Scheme + "://" + u.Host + strings.TrimRight(u.Path, "/")
 }
@@ -0,0 +1,85 @@
+// client_test.go
+package ollamago
+
+import (
+	"context"
+	"errors"
+	"testing"
+)
+
+func TestParseHost(t *testing.T) {
+	t.Setenv("OLLAMA_SCHEME", "")
+
+	tests := []struct {
+		host string
+		want string
+	}{
+		{"", "http://127.0.0.1:11434"},
+		{"  ", "http://127.0.0.1:11434"},
+		{"example.com", "http://example.com:11434"},
+		{"example.com:8080", "http://example.com:8080"},
+		{"0.0.0.0", "http://0.0.0.0:11434"},
+		{"::1", "http://[::1]:11434"},
+		{"[::1]", "http://[::1]:11434"},
+		{"[::1]:8080", "http://[::1]:8080"},
+		{"https://example.com", "https://example.com:443"},
+		{"https://example.com:8443", "https://example.com:8443"},
+		{"http://example.com/", "http://example.com:11434"},
+		{"http://example.com:8080/ollama/", "http://example.com:8080/ollama"},
+		{"http://", "http://127.0.0.1:11434"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.host, func(t *testing.T) {
+			if got := parseHost(tt.host); got != tt.want {
+				t.Errorf("parseHost(%q) = %q, want %q", tt.host, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseHostScheme(t *testing.T) {
+	t.Setenv("OLLAMA_SCHEME", "https")
+
+	if got, want := parseHost("example.com"), "https://example.com:443"; got != want {
+		t.Errorf("parseHost = %q, want %q", got, want)
+	}
+}
+
+func TestErrInvalidRequest(t *testing.T) {
+	// Nothing listens here, so an error that reaches the network is not a
+	// RequestError
+	c := NewClient(WithBaseURL("http://127.0.0.1:1"))
+	ctx := context.Background()
+
+	tests := map[string]func() error{
+		"generate without model": func() error {
+			_, err := c.Generate(ctx, GenerateRequest{Prompt: "hi"})
+			return err
+		},
+		"chat without model": func() error {
+			_, err := c.Chat(ctx, ChatRequest{Messages: []Message{{Role: "user", Content: "hi"}}})
+			return err
+		},
+		"options validate": func() error {
+			return (&Options{TopP: ptr(1.5)}).Validate()
+		},
+		"message validate": func() error {
+			return Message{Role: "narrator", Content: "hi"}.Validate()
+		},
+		"chunk text": func() error {
+			_, err := ChunkText("a b c", 0, 0)
+			return err
+		},
+		"generate n": func() error {
+			_, err := c.GenerateN(ctx, GenerateRequest{Model: "m", Prompt: "hi"}, -1)
+			return err
+		},
+	}
+	for name, fn := range tests {
+		t.Run(name, func(t *testing.T) {
+			if err := fn(); !errors.Is(err, ErrInvalidRequest) {
+				t.Errorf("err = %v, want ErrInvalidRequest", err)
+			}
+		})
+	}
+}
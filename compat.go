@@ -0,0 +1,335 @@
+// compat.go
+package ollamago
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// VersionCompatPolicy decides what happens when a request uses a feature the
+// server is too old to support
+type VersionCompatPolicy int
+
+const (
+	// VersionCompatOff sends requests unchanged
+	VersionCompatOff VersionCompatPolicy = iota
+	// VersionCompatStrip removes unsupported fields before sending
+	VersionCompatStrip
+	// VersionCompatError fails the request with ErrUnsupportedByServer
+	VersionCompatError
+)
+
+// ErrUnsupportedByServer is returned under VersionCompatError when a request
+// uses a feature the server version does not support
+var ErrUnsupportedByServer = errors.New("unsupported by server version")
+
+// compatFeature is a request feature and the first server version with it
+type compatFeature struct {
+	name       string
+	minVersion string
+}
+
+var (
+	featureTools        = compatFeature{name: "tools", minVersion: "0.3.0"}
+	featureFormatSchema = compatFeature{name: "format schema", minVersion: "0.5.0"}
+	featureThink        = compatFeature{name: "think", minVersion: "0.9.0"}
+	featureChat         = compatFeature{name: "chat", minVersion: "0.1.14"}
+	featureDimensions   = compatFeature{name: "embedding dimensions", minVersion: "0.12.0"}
+)
+
+// WithVersionCompat checks generate, chat and embed requests against the
+// server version, fetched once and cached, and strips or rejects fields the
+// server does not support according to policy. Stripped fields are reported
+// to the debug logger when one is set. When the version cannot be fetched,
+// VersionCompatStrip sends requests unchanged and VersionCompatError fails
+// them.
+func WithVersionCompat(policy VersionCompatPolicy) Option {
+	return func(c *Client) {
+		c.compat = policy
+	}
+}
+
+// serverVersion returns the cached server version, fetching it on first use
+func (c *Client) serverVersion(ctx context.Context) (string, error) {
+	c.versionMu.Lock()
+	defer c.versionMu.Unlock()
+	if c.version != "" {
+		return c.version, nil
+	}
+
+	resp, err := c.Version(ctx)
+	if err != nil {
+		return "", fmt.Errorf("fetching server version: %w", err)
+	}
+	c.version = resp.Version
+	return c.version, nil
+}
+
+// unsupportedFeatures returns the features the server does not support.
+// Under VersionCompatError any unsupported feature is an error.
+func (c *Client) unsupportedFeatures(ctx context.Context, features ...compatFeature) ([]compatFeature, error) {
+	if c.compat == VersionCompatOff || len(features) == 0 {
+		return nil, nil
+	}
+
+	version, err := c.serverVersion(ctx)
+	if err != nil {
+		if c.compat == VersionCompatError {
+			return nil, err
+		}
+		if c.debugLog != nil {
+			c.debugLog.Printf("ollama: %v, sending the request unchanged", err)
+		}
+		return nil, nil
+	}
+
+	var unsupported []compatFeature
+	for _, f := range features {
+		if compareVersions(version, f.minVersion) < 0 {
+			if c.compat == VersionCompatError {
+				return nil, fmt.Errorf("%w: %s requires %s, server is %s", ErrUnsupportedByServer, f.name, f.minVersion, version)
+			}
+			if c.debugLog != nil {
+				c.debugLog.Printf("ollama: server %s does not support %s (requires %s), removing it from the request", version, f.name, f.minVersion)
+			}
+			unsupported = append(unsupported, f)
+		}
+	}
+	return unsupported, nil
+}
+
+// usesFormatSchema reports whether a request sends a schema as its format,
+// either as FormatSchema or as a Format string holding a JSON object
+func usesFormatSchema(format string, schema json.RawMessage) bool {
+	if len(schema) > 0 {
+		return true
+	}
+	_, ok := schemaFormat(format)
+	return ok
+}
+
+// stripFormatSchema removes the schema from a request's format, keeping a
+// plain Format string such as "json"
+func stripFormatSchema(format string) (string, json.RawMessage) {
+	if _, ok := schemaFormat(format); ok {
+		return "", nil
+	}
+	return format, nil
+}
+
+// compatGenerate applies the version compatibility policy to a generate
+// request
+func (c *Client) compatGenerate(ctx context.Context, req *GenerateRequest) error {
+	var used []compatFeature
+	if usesFormatSchema(req.Format, req.FormatSchema) {
+		used = append(used, featureFormatSchema)
+	}
+	if req.Think != nil {
+		used = append(used, featureThink)
+	}
+
+	unsupported, err := c.unsupportedFeatures(ctx, used...)
+	if err != nil {
+		return err
+	}
+	for _, f := range unsupported {
+		switch f {
+		case featureFormatSchema:
+			req.Format, req.FormatSchema = stripFormatSchema(req.Format)
+		case featureThink:
+			req.Think = nil
+		}
+	}
+	return nil
+}
+
+// compatChat applies the version compatibility policy to a chat request
+func (c *Client) compatChat(ctx context.Context, req *ChatRequest) error {
+	var used []compatFeature
+	if usesFormatSchema(req.Format, req.FormatSchema) {
+		used = append(used, featureFormatSchema)
+	}
+	if req.Think != nil {
+		used = append(used, featureThink)
+	}
+	if len(req.Tools) > 0 {
+		used = append(used, featureTools)
+	}
+
+	unsupported, err := c.unsupportedFeatures(ctx, used...)
+	if err != nil {
+		return err
+	}
+	for _, f := range unsupported {
+		switch f {
+		case featureFormatSchema:
+			req.Format, req.FormatSchema = stripFormatSchema(req.Format)
+		case featureThink:
+			req.Think = nil
+		case featureTools:
+			req.Tools = nil
+		}
+	}
+	return nil
+}
+
+// compatEmbed applies the version compatibility policy to an embed request
+func (c *Client) compatEmbed(ctx context.Context, req *EmbedRequest) error {
+	var used []compatFeature
+	if req.Dimensions != nil {
+		used = append(used, featureDimensions)
+	}
+
+	unsupported, err := c.unsupportedFeatures(ctx, used...)
+	if err != nil {
+		return err
+	}
+	for _, f := range unsupported {
+		switch f {
+		case featureDimensions:
+			req.Dimensions = nil
+		}
+	}
+	return nil
+}
+
+// Complete runs a chat request on any server. Servers that support the
+// chat endpoint receive the request unchanged; for older servers, and those
+// that answer /api/chat with 404, the messages are rendered into a prompt
+// with the model's template (see RenderChat) and sent as a raw generate
+// request, and the result is returned as a ChatResponse. Tool calls are not
+// parsed on the generate path.
+func (c *Client) Complete(ctx context.Context, req ChatRequest) (*ChatResponse, error) {
+	if req.Model == "" {
+		return nil, &RequestError{Message: "model is required"}
+	}
+
+	if c.supportsChat(ctx) {
+		resp, err := c.Chat(ctx, req)
+		if !isMissingEndpoint(err) {
+			return resp, err
+		}
+		if c.debugLog != nil {
+			c.debugLog.Printf("ollama: server has no chat endpoint, falling back to generate")
+		}
+	}
+	return c.chatViaGenerate(ctx, req)
+}
+
+// supportsChat reports whether the server version has the chat endpoint.
+// When the version cannot be fetched the endpoint is assumed to exist.
+func (c *Client) supportsChat(ctx context.Context) bool {
+	version, err := c.serverVersion(ctx)
+	if err != nil {
+		return true
+	}
+	return compareVersions(version, featureChat.minVersion) >= 0
+}
+
+// isMissingEndpoint reports whether err is the server's 404 for an unknown
+// path, as opposed to a missing model
+func isMissingEndpoint(err error) bool {
+	var respErr *ResponseError
+	return errors.As(err, &respErr) && respErr.StatusCode == http.StatusNotFound &&
+		strings.Contains(strings.ToLower(respErr.Message), "page not found")
+}
+
+// chatViaGenerate runs a chat request as a raw generate request
+func (c *Client) chatViaGenerate(ctx context.Context, req ChatRequest) (*ChatResponse, error) {
+	prompt, err := c.RenderChat(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+
+	genReq := GenerateRequest{
+		Model:        req.Model,
+		Prompt:       prompt,
+		Raw:          true,
+		Format:       req.Format,
+		FormatSchema: req.FormatSchema,
+		Options:      req.Options,
+		KeepAlive:    req.KeepAlive,
+		Think:        req.Think,
+	}
+	for _, msg := range req.Messages {
+		genReq.Images = append(genReq.Images, msg.Images...)
+	}
+
+	resp, err := c.Generate(ctx, genReq)
+	if err != nil {
+		return nil, err
+	}
+	return &ChatResponse{
+		Model:     resp.Model,
+		CreatedAt: resp.CreatedAt,
+		Message: Message{
+			Role:     "assistant",
+			Content:  resp.Response,
+			Thinking: resp.Thinking,
+		},
+		Done:               resp.Done,
+		DoneReason:         resp.DoneReason,
+		TotalDuration:      resp.TotalDuration,
+		LoadDuration:       resp.LoadDuration,
+		PromptEvalCount:    resp.PromptEvalCount,
+		PromptEvalDuration: resp.PromptEvalDuration,
+		EvalCount:          resp.EvalCount,
+		EvalDuration:       resp.EvalDuration,
+		Prompt:             resp.Prompt,
+	}, nil
+}
+
+// compareVersions compares two dotted version strings numerically. Suffixes
+// such as "-rc1" are ignored, and development builds reporting 0.0.0 are
+// treated as newer than any release.
+func compareVersions(a, b string) int {
+	pa, pb := versionParts(a), versionParts(b)
+	if isDevVersion(pa) && !isDevVersion(pb) {
+		return 1
+	}
+	for i := 0; i < len(pa) || i < len(pb); i++ {
+		var x, y int
+		if i < len(pa) {
+			x = pa[i]
+		}
+		if i < len(pb) {
+			y = pb[i]
+		}
+		if x != y {
+			if x < y {
+				return -1
+			}
+			return 1
+		}
+	}
+	return 0
+}
+
+// versionParts splits a version such as "v0.5.7-rc1" into its numbers
+func versionParts(v string) []int {
+	v = strings.TrimPrefix(v, "v")
+	if i := strings.IndexAny(v, "-+ "); i != -1 {
+		v = v[:i]
+	}
+	var parts []int
+	for _, s := range strings.Split(v, ".") {
+		n, _ := strconv.Atoi(s)
+		parts = append(parts, n)
+	}
+	return parts
+}
+
+// isDevVersion reports whether a version is the 0.0.0 of development builds
+func isDevVersion(parts []int) bool {
+	for _, p := range parts {
+		if p != 0 {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,232 @@
+// compat_test.go
+package ollamago
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestVersionCompatFormatSchema(t *testing.T) {
+	var sent map[string]json.RawMessage
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		if r.URL.Path == "/api/version" {
+			fmt.Fprint(w, `{"version":"0.4.0"}`)
+			return
+		}
+		sent = nil
+		json.NewDecoder(r.Body).Decode(&sent)
+		fmt.Fprint(w, `{"response":"{}","done":true}`)
+	}))
+	defer srv.Close()
+
+	tests := []struct {
+		name       string
+		req        GenerateRequest
+		wantFormat string
+		wantReject bool
+	}{
+		{"schema", GenerateRequest{FormatSchema: json.RawMessage(`{"type":"object"}`)}, "", true},
+		{"schema string", GenerateRequest{Format: `{"type":"object"}`}, "", true},
+		{"schema with json fallback", GenerateRequest{Format: "json", FormatSchema: json.RawMessage(`{"type":"object"}`)}, `"json"`, true},
+		{"json", GenerateRequest{Format: "json"}, `"json"`, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := tt.req
+			req.Model = "m"
+
+			strip := NewClient(WithBaseURL(srv.URL), WithVersionCompat(VersionCompatStrip))
+			if _, err := strip.Generate(context.Background(), req); err != nil {
+				t.Fatalf("Generate: %v", err)
+			}
+			if got := string(sent["format"]); got != tt.wantFormat {
+				t.Errorf("sent format = %s, want %s", got, tt.wantFormat)
+			}
+
+			reject := NewClient(WithBaseURL(srv.URL), WithVersionCompat(VersionCompatError))
+			_, err := reject.Generate(context.Background(), req)
+			if errors.Is(err, ErrUnsupportedByServer) != tt.wantReject {
+				t.Errorf("err = %v, want rejected: %v", err, tt.wantReject)
+			}
+		})
+	}
+}
+
+func TestVersionCompatFeatures(t *testing.T) {
+	think := true
+	dims := 2
+	features := []struct {
+		name  string
+		field string
+		call  func(*Client) error
+	}{
+		{"generate think", "think", func(c *Client) error {
+			_, err := c.Generate(context.Background(), GenerateRequest{Model: "m", Think: &think})
+			return err
+		}},
+		{"chat think", "think", func(c *Client) error {
+			_, err := c.Chat(context.Background(), ChatRequest{Model: "m", Think: &think})
+			return err
+		}},
+		{"chat tools", "tools", func(c *Client) error {
+			_, err := c.Chat(context.Background(), ChatRequest{Model: "m", Tools: []Tool{{Type: "function", Function: Function{Name: "f"}}}})
+			return err
+		}},
+		{"embed dimensions", "dimensions", func(c *Client) error {
+			_, err := c.Embed(context.Background(), EmbedRequest{Model: "m", Input: "text", Dimensions: &dims})
+			return err
+		}},
+	}
+	servers := []struct {
+		version   string
+		supported bool
+	}{
+		{"0.2.0", false},
+		{"0.12.3", true},
+	}
+
+	for _, server := range servers {
+		var sent map[string]json.RawMessage
+		srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Content-Type", "application/json")
+			if r.URL.Path == "/api/version" {
+				fmt.Fprintf(w, `{"version":%q}`, server.version)
+				return
+			}
+			sent = nil
+			json.NewDecoder(r.Body).Decode(&sent)
+			if r.URL.Path == "/api/embed" {
+				fmt.Fprint(w, `{"embeddings":[[0.6,0.8]]}`)
+				return
+			}
+			fmt.Fprint(w, `{"response":"ok","message":{"role":"assistant","content":"ok"},"done":true}`)
+		}))
+
+		for _, f := range features {
+			t.Run(server.version+"/"+f.name, func(t *testing.T) {
+				strip := NewClient(WithBaseURL(srv.URL), WithVersionCompat(VersionCompatStrip))
+				if err := f.call(strip); err != nil {
+					t.Fatalf("strip: %v", err)
+				}
+				if _, ok := sent[f.field]; ok != server.supported {
+					t.Errorf("strip: %s sent = %v, want %v", f.field, ok, server.supported)
+				}
+
+				reject := NewClient(WithBaseURL(srv.URL), WithVersionCompat(VersionCompatError))
+				err := f.call(reject)
+				if errors.Is(err, ErrUnsupportedByServer) == server.supported {
+					t.Errorf("error: err = %v, want rejected: %v", err, !server.supported)
+				}
+				if server.supported {
+					if _, ok := sent[f.field]; !ok {
+						t.Errorf("error: %s was not sent", f.field)
+					}
+				}
+			})
+		}
+		srv.Close()
+	}
+}
+
+func TestVersionCompatUnknownVersion(t *testing.T) {
+	var sent map[string]json.RawMessage
+	versionCalls := 0
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		if r.URL.Path == "/api/version" {
+			versionCalls++
+			w.WriteHeader(http.StatusInternalServerError)
+			fmt.Fprint(w, `{"error":"unavailable"}`)
+			return
+		}
+		sent = nil
+		json.NewDecoder(r.Body).Decode(&sent)
+		fmt.Fprint(w, `{"response":"ok","done":true}`)
+	}))
+	defer srv.Close()
+
+	think := true
+	req := GenerateRequest{Model: "m", Think: &think}
+
+	strip := NewClient(WithBaseURL(srv.URL), WithVersionCompat(VersionCompatStrip))
+	for i := 0; i < 2; i++ {
+		if _, err := strip.Generate(context.Background(), req); err != nil {
+			t.Fatalf("strip: %v", err)
+		}
+		if _, ok := sent["think"]; !ok {
+			t.Error("strip: think was removed though the version is unknown")
+		}
+	}
+	if versionCalls != 2 {
+		t.Errorf("version fetched %d times, want 2 as failures are not cached", versionCalls)
+	}
+
+	reject := NewClient(WithBaseURL(srv.URL), WithVersionCompat(VersionCompatError))
+	if _, err := reject.Generate(context.Background(), req); err == nil {
+		t.Error("error: expected the version failure")
+	}
+}
+
+func TestComplete(t *testing.T) {
+	tests := []struct {
+		name     string
+		version  string
+		noChat   bool
+		wantPath string
+	}{
+		{"chat", "0.5.0", false, "/api/chat"},
+		{"old server", "0.1.0", false, "/api/generate"},
+		{"missing chat endpoint", "0.5.0", true, "/api/generate"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var generated GenerateRequest
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				switch {
+				case r.URL.Path == "/api/version":
+					fmt.Fprintf(w, `{"version":%q}`, tt.version)
+				case r.URL.Path == "/api/show":
+					fmt.Fprint(w, `{"template":"{{ .System }}|{{ .Prompt }}"}`)
+				case r.URL.Path == "/api/chat" && !tt.noChat:
+					fmt.Fprint(w, `{"message":{"role":"assistant","content":"/api/chat"},"done":true}`)
+				case r.URL.Path == "/api/generate":
+					json.NewDecoder(r.Body).Decode(&generated)
+					fmt.Fprint(w, `{"response":"/api/generate","done":true,"eval_count":5}`)
+				default:
+					http.NotFound(w, r)
+				}
+			}))
+			defer srv.Close()
+
+			c := NewClient(WithBaseURL(srv.URL))
+			resp, err := c.Complete(context.Background(), ChatRequest{
+				Model: "m",
+				Messages: []Message{
+					{Role: "system", Content: "be brief"},
+					{Role: "user", Content: "hi"},
+				},
+			})
+			if err != nil {
+				t.Fatalf("Complete: %v", err)
+			}
+			if resp.Message.Role != "assistant" || resp.Message.Content != tt.wantPath {
+				t.Errorf("message = %+v, want assistant content %q", resp.Message, tt.wantPath)
+			}
+			if tt.wantPath != "/api/generate" {
+				return
+			}
+			if !generated.Raw || generated.Prompt != "be brief|hi" {
+				t.Errorf("generate request = %+v, want raw prompt %q", generated, "be brief|hi")
+			}
+			if resp.EvalCount != 5 {
+				t.Errorf("eval count = %d, want 5", resp.EvalCount)
+			}
+		})
+	}
+}
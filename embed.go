@@ -0,0 +1,462 @@
+// embed.go
+package ollamago
+
+import (
+	"context"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// CosineSimilarity returns the cosine of the angle between two vectors, or
+// zero when their lengths differ or either is all zeros
+func CosineSimilarity(a, b []float64) float64 {
+	if len(a) != len(b) {
+		return 0
+	}
+	var dot, normA, normB float64
+	for i := range a {
+		dot += a[i] * b[i]
+		normA += a[i] * a[i]
+		normB += b[i] * b[i]
+	}
+	if normA == 0 || normB == 0 {
+		return 0
+	}
+	return dot / (math.Sqrt(normA) * math.Sqrt(normB))
+}
+
+// LabeledPair is a pair of texts labeled as similar or not, used to compare
+// embedding models
+type LabeledPair struct {
+	A       string
+	B       string
+	Similar bool
+}
+
+// DefaultEmbedBatchSize is the number of inputs EmbedBatches sends per
+// request when given a batch size that is not positive
+const DefaultEmbedBatchSize = 64
+
+// EmbedBatches embeds a large list of inputs in batches of batchSize, one
+// request per batch, and calls onBatch, if not nil, after each batch with
+// the number of inputs embedded so far and the total. The embed endpoint
+// does not stream, so progress is reported per batch. The vectors are
+// returned in input order, with durations and counts summed over the
+// batches. On error the vectors embedded so far are returned with it. To
+// embed a long document, see EmbedDocumentStream.
+func (c *Client) EmbedBatches(ctx context.Context, req EmbedRequest, batchSize int, onBatch func(done, total int)) (*EmbedResponse, error) {
+	var inputs []string
+	switch input := req.Input.(type) {
+	case string:
+		inputs = []string{input}
+	case []string:
+		inputs = input
+	default:
+		return nil, &RequestError{Message: "input must be a string or []string"}
+	}
+	if len(inputs) == 0 {
+		return nil, &RequestError{Message: "input is required"}
+	}
+	if batchSize < 1 {
+		batchSize = DefaultEmbedBatchSize
+	}
+
+	result := &EmbedResponse{Model: req.Model, Embeddings: make([][]float64, 0, len(inputs))}
+	for start := 0; start < len(inputs); start += batchSize {
+		end := min(start+batchSize, len(inputs))
+		batch := req
+		batch.Input = inputs[start:end]
+
+		resp, err := c.Embed(ctx, batch)
+		if err == nil && len(resp.Embeddings) != end-start {
+			err = fmt.Errorf("got %d embeddings for %d inputs", len(resp.Embeddings), end-start)
+		}
+		if err != nil {
+			return result, fmt.Errorf("embedding inputs %d to %d: %w", start, end-1, err)
+		}
+
+		result.Embeddings = append(result.Embeddings, resp.Embeddings...)
+		result.TotalDuration += resp.TotalDuration
+		result.LoadDuration += resp.LoadDuration
+		result.PromptEvalCount += resp.PromptEvalCount
+		if resp.Model != "" {
+			result.Model = resp.Model
+		}
+		if onBatch != nil {
+			onBatch(end, len(inputs))
+		}
+	}
+	return result, nil
+}
+
+// evaluateConcurrency bounds how many models EvaluateEmbeddings runs at once
+const evaluateConcurrency = 4
+
+// EvaluateEmbeddings scores each embedding model on how well the cosine
+// similarity of its embeddings separates similar from dissimilar pairs. The
+// score is the accuracy at the best similarity threshold, between 0 and 1.
+// Models that fail are left out of the result and reported in the error.
+func (c *Client) EvaluateEmbeddings(ctx context.Context, models []string, pairs []LabeledPair) (map[string]float64, error) {
+	if len(pairs) == 0 {
+		return nil, &RequestError{Message: "at least one labeled pair is required"}
+	}
+
+	// Embed every distinct text once per model
+	index := make(map[string]int)
+	var texts []string
+	for _, p := range pairs {
+		for _, text := range []string{p.A, p.B} {
+			if _, ok := index[text]; !ok {
+				index[text] = len(texts)
+				texts = append(texts, text)
+			}
+		}
+	}
+
+	var (
+		mu     sync.Mutex
+		wg     sync.WaitGroup
+		scores = make(map[string]float64)
+		errs   []error
+		sem    = make(chan struct{}, evaluateConcurrency)
+	)
+	for _, model := range models {
+		wg.Add(1)
+		go func(model string) {
+			defer wg.Done()
+			select {
+			case sem <- struct{}{}:
+				defer func() { <-sem }()
+			case <-ctx.Done():
+				mu.Lock()
+				errs = append(errs, fmt.Errorf("%s: %w", model, ctx.Err()))
+				mu.Unlock()
+				return
+			}
+
+			resp, err := c.Embed(ctx, EmbedRequest{Model: model, Input: texts})
+			if err == nil && len(resp.Embeddings) != len(texts) {
+				err = fmt.Errorf("got %d embeddings for %d inputs", len(resp.Embeddings), len(texts))
+			}
+			if err != nil {
+				mu.Lock()
+				errs = append(errs, fmt.Errorf("%s: %w", model, err))
+				mu.Unlock()
+				return
+			}
+
+			similarities := make([]float64, len(pairs))
+			for i, p := range pairs {
+				similarities[i] = CosineSimilarity(resp.Embeddings[index[p.A]], resp.Embeddings[index[p.B]])
+			}
+
+			mu.Lock()
+			scores[model] = bestThresholdAccuracy(similarities, pairs)
+			mu.Unlock()
+		}(model)
+	}
+	wg.Wait()
+
+	return scores, errors.Join(errs...)
+}
+
+// bestThresholdAccuracy returns the highest accuracy achieved by labeling a
+// pair similar when its similarity is at least some threshold
+func bestThresholdAccuracy(similarities []float64, pairs []LabeledPair) float64 {
+	order := make([]int, len(pairs))
+	positives := 0
+	for i := range order {
+		order[i] = i
+		if pairs[i].Similar {
+			positives++
+		}
+	}
+	sort.Slice(order, func(i, j int) bool {
+		return similarities[order[i]] < similarities[order[j]]
+	})
+
+	// Start with every pair labeled similar, then move the threshold above
+	// each score in turn
+	correct := positives
+	best := correct
+	for k := 0; k < len(order); k++ {
+		if pairs[order[k]].Similar {
+			correct--
+		} else {
+			correct++
+		}
+		// Only thresholds between distinct scores are achievable
+		if k+1 < len(order) && similarities[order[k+1]] == similarities[order[k]] {
+			continue
+		}
+		if correct > best {
+			best = correct
+		}
+	}
+	return float64(best) / float64(len(pairs))
+}
+
+// Precision is the numeric format used to store embedding values
+type Precision int
+
+// Precisions supported by QuantizeEmbedding
+const (
+	PrecisionFloat32 Precision = iota
+	PrecisionFloat16
+	PrecisionFloat64
+)
+
+// size returns the number of bytes a value takes at the precision
+func (p Precision) size() int {
+	switch p {
+	case PrecisionFloat16:
+		return 2
+	case PrecisionFloat64:
+		return 8
+	default:
+		return 4
+	}
+}
+
+// EmbeddingStorageBytes returns the raw size of count vectors of dim values
+// stored at the given precision, excluding any index or metadata overhead
+func EmbeddingStorageBytes(dim, count int, precision Precision) int64 {
+	return int64(dim) * int64(count) * int64(precision.size())
+}
+
+// QuantizeEmbedding packs a vector into little-endian values of the given
+// precision. Float16 keeps about three significant decimal digits; values
+// beyond its range become infinities.
+func QuantizeEmbedding(v []float64, p Precision) []byte {
+	size := p.size()
+	out := make([]byte, len(v)*size)
+	for i, x := range v {
+		b := out[i*size:]
+		switch p {
+		case PrecisionFloat16:
+			binary.LittleEndian.PutUint16(b, float32ToFloat16(float32(x)))
+		case PrecisionFloat64:
+			binary.LittleEndian.PutUint64(b, math.Float64bits(x))
+		default:
+			binary.LittleEndian.PutUint32(b, math.Float32bits(float32(x)))
+		}
+	}
+	return out
+}
+
+// DequantizeEmbedding unpacks a vector packed by QuantizeEmbedding
+func DequantizeEmbedding(b []byte, p Precision) ([]float64, error) {
+	size := p.size()
+	if len(b)%size != 0 {
+		return nil, fmt.Errorf("embedding data of %d bytes is not a multiple of %d", len(b), size)
+	}
+	out := make([]float64, len(b)/size)
+	for i := range out {
+		v := b[i*size:]
+		switch p {
+		case PrecisionFloat16:
+			out[i] = float64(float16ToFloat32(binary.LittleEndian.Uint16(v)))
+		case PrecisionFloat64:
+			out[i] = math.Float64frombits(binary.LittleEndian.Uint64(v))
+		default:
+			out[i] = float64(math.Float32frombits(binary.LittleEndian.Uint32(v)))
+		}
+	}
+	return out, nil
+}
+
+// float32ToFloat16 converts to IEEE 754 half precision, rounding to nearest
+// even
+func float32ToFloat16(f float32) uint16 {
+	bits := math.Float32bits(f)
+	sign := uint16(bits>>16) & 0x8000
+	exp := int(bits>>23&0xff) - 127 + 15
+	mant := bits & 0x7fffff
+
+	switch {
+	case bits>>23&0xff == 0xff:
+		if mant != 0 {
+			return sign | 0x7e00
+		}
+		return sign | 0x7c00
+	case exp >= 31:
+		return sign | 0x7c00
+	case exp <= 0:
+		// Subnormal half, or zero when too small
+		if exp < -10 {
+			return sign
+		}
+		mant |= 0x800000
+		shift := uint32(14 - exp)
+		half := mant >> shift
+		rem := mant & (1<<shift - 1)
+		halfway := uint32(1) << (shift - 1)
+		if rem > halfway || (rem == halfway && half&1 == 1) {
+			half++
+		}
+		return sign | uint16(half)
+	}
+
+	half := uint32(exp)<<10 | mant>>13
+	rem := mant & 0x1fff
+	if rem > 0x1000 || (rem == 0x1000 && half&1 == 1) {
+		// A carry into the exponent rounds up to the next power of two or
+		// to infinity, as it should
+		half++
+	}
+	return sign | uint16(half)
+}
+
+// float16ToFloat32 converts from IEEE 754 half precision
+func float16ToFloat32(h uint16) float32 {
+	sign := uint32(h&0x8000) << 16
+	exp := uint32(h>>10) & 0x1f
+	mant := uint32(h & 0x3ff)
+
+	switch exp {
+	case 0:
+		f := float32(mant) / (1 << 24)
+		if sign != 0 {
+			f = -f
+		}
+		return f
+	case 31:
+		return math.Float32frombits(sign | 0x7f800000 | mant<<13)
+	}
+	return math.Float32frombits(sign | (exp-15+127)<<23 | mant<<13)
+}
+
+// UnmarshalJSON decodes embed responses from any server version, accepting
+// the vectors under "embeddings" or the legacy "embedding" key, as a list
+// of vectors or a single one
+func (r *EmbedResponse) UnmarshalJSON(data []byte) error {
+	type alias EmbedResponse
+	var raw struct {
+		alias
+		Embeddings json.RawMessage `json:"embeddings"`
+		Embedding  json.RawMessage `json:"embedding"`
+	}
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return err
+	}
+
+	*r = EmbedResponse(raw.alias)
+	vectors, err := decodeVectors(raw.Embeddings, raw.Embedding)
+	if err != nil {
+		return err
+	}
+	r.Embeddings = vectors
+	return nil
+}
+
+// UnmarshalJSON decodes legacy embeddings responses, also accepting the
+// first vector of an "embeddings" list
+func (r *EmbeddingsResponse) UnmarshalJSON(data []byte) error {
+	var raw struct {
+		Embeddings json.RawMessage `json:"embeddings"`
+		Embedding  json.RawMessage `json:"embedding"`
+	}
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return err
+	}
+
+	vectors, err := decodeVectors(raw.Embedding, raw.Embeddings)
+	if err != nil {
+		return err
+	}
+	r.Embedding = nil
+	if len(vectors) > 0 {
+		r.Embedding = vectors[0]
+	}
+	return nil
+}
+
+// decodeVectors decodes the first non-empty of several fields holding
+// either one vector or a list of vectors
+func decodeVectors(fields ...json.RawMessage) ([][]float64, error) {
+	for _, field := range fields {
+		if len(field) == 0 || string(field) == "null" {
+			continue
+		}
+
+		var vectors [][]float64
+		if err := json.Unmarshal(field, &vectors); err == nil {
+			if len(vectors) == 0 {
+				continue
+			}
+			return vectors, nil
+		}
+		var vector []float64
+		if err := json.Unmarshal(field, &vector); err != nil {
+			return nil, fmt.Errorf("decoding embeddings: %w", err)
+		}
+		if len(vector) == 0 {
+			continue
+		}
+		return [][]float64{vector}, nil
+	}
+	return nil, nil
+}
+
+// ErrDegenerateEmbedding is returned by EmbedResponse.Validate when a vector
+// cannot be a real embedding
+var ErrDegenerateEmbedding = errors.New("degenerate embedding")
+
+// Validate checks that no vector is empty, all zeros, constant, or holds
+// NaN or infinite values, and that all vectors have the same dimension as
+// the first, which are signs of a failed embedding. It returns an error
+// listing the indices of the bad vectors.
+func (r *EmbedResponse) Validate() error {
+	var bad []string
+	for i, v := range r.Embeddings {
+		problem := degenerate(v)
+		if problem == "" && i > 0 && len(v) != len(r.Embeddings[0]) {
+			problem = fmt.Sprintf("dimension %d, expected %d", len(v), len(r.Embeddings[0]))
+		}
+		if problem != "" {
+			bad = append(bad, fmt.Sprintf("%d (%s)", i, problem))
+		}
+	}
+	if len(bad) > 0 {
+		return fmt.Errorf("%w: vectors %s", ErrDegenerateEmbedding, strings.Join(bad, ", "))
+	}
+	return nil
+}
+
+// degenerate describes what is wrong with a vector, or returns "" when
+// nothing is
+func degenerate(v []float64) string {
+	if len(v) == 0 {
+		return "empty"
+	}
+	constant := true
+	for _, x := range v {
+		if math.IsNaN(x) || math.IsInf(x, 0) {
+			return "not finite"
+		}
+		if x != v[0] {
+			constant = false
+		}
+	}
+	switch {
+	case constant && v[0] == 0:
+		return "all zeros"
+	case constant && len(v) > 1:
+		return "constant"
+	}
+	return ""
+}
+
+// WithEmbeddingValidation makes Embed check its response with
+// EmbedResponse.Validate and fail on degenerate vectors
+func WithEmbeddingValidation(enabled bool) Option {
+	return func(c *Client) {
+		c.validateEmbeddings = enabled
+	}
+}
@@ -0,0 +1,314 @@
+// embed_test.go
+package ollamago
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"slices"
+	"strings"
+	"testing"
+)
+
+// embedServer answers /api/embed with one vector per input from embed, or
+// with a server error for the model "broken"
+func embedServer(t *testing.T, embed func(model, text string) []float64) *httptest.Server {
+	t.Helper()
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		var req struct {
+			Model string   `json:"model"`
+			Input []string `json:"input"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			t.Errorf("decoding request: %v", err)
+		}
+		if req.Model == "broken" {
+			w.WriteHeader(http.StatusInternalServerError)
+			fmt.Fprint(w, `{"error":"model crashed"}`)
+			return
+		}
+		embeddings := make([][]float64, len(req.Input))
+		for i, text := range req.Input {
+			embeddings[i] = embed(req.Model, text)
+		}
+		json.NewEncoder(w).Encode(map[string]interface{}{"model": req.Model, "embeddings": embeddings})
+	}))
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+func TestEvaluateEmbeddings(t *testing.T) {
+	animals := map[string]bool{"cat": true, "kitten": true}
+	srv := embedServer(t, func(model, text string) []float64 {
+		if model == "good" && !animals[text] {
+			return []float64{0, 1}
+		}
+		// "flat" embeds every text the same way
+		return []float64{1, 0}
+	})
+	c := NewClient(WithBaseURL(srv.URL))
+
+	pairs := []LabeledPair{
+		{A: "cat", B: "kitten", Similar: true},
+		{A: "car", B: "truck", Similar: true},
+		{A: "cat", B: "car"},
+		{A: "kitten", B: "truck"},
+	}
+	scores, err := c.EvaluateEmbeddings(context.Background(), []string{"good", "flat", "broken"}, pairs)
+	if err == nil || !strings.Contains(err.Error(), "broken") {
+		t.Errorf("err = %v, want the broken model's failure", err)
+	}
+	if len(scores) != 2 {
+		t.Fatalf("scores = %v, want good and flat only", scores)
+	}
+	if scores["good"] != 1 {
+		t.Errorf("good = %v, want 1", scores["good"])
+	}
+	if scores["flat"] != 0.5 {
+		t.Errorf("flat = %v, want 0.5", scores["flat"])
+	}
+
+	if _, err := c.EvaluateEmbeddings(context.Background(), []string{"good"}, nil); err == nil {
+		t.Error("no error without pairs")
+	}
+}
+
+func TestQuantizeEmbedding(t *testing.T) {
+	v := []float64{1, -0.5, 0.25, 0, 1.0 / 3}
+	tests := []struct {
+		name      string
+		precision Precision
+		size      int
+		// tolerance for 1/3, which no precision represents exactly
+		tolerance float64
+	}{
+		{"float16", PrecisionFloat16, 2, 1e-3},
+		{"float32", PrecisionFloat32, 4, 1e-7},
+		{"float64", PrecisionFloat64, 8, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got, want := EmbeddingStorageBytes(768, 1000, tt.precision), int64(768*1000*tt.size); got != want {
+				t.Errorf("EmbeddingStorageBytes = %d, want %d", got, want)
+			}
+
+			b := QuantizeEmbedding(v, tt.precision)
+			if len(b) != len(v)*tt.size {
+				t.Fatalf("%d bytes, want %d", len(b), len(v)*tt.size)
+			}
+			got, err := DequantizeEmbedding(b, tt.precision)
+			if err != nil {
+				t.Fatal(err)
+			}
+			for i := range v {
+				if math.Abs(got[i]-v[i]) > tt.tolerance {
+					t.Errorf("value %d = %v, want %v", i, got[i], v[i])
+				}
+			}
+
+			if _, err := DequantizeEmbedding(b[:len(b)-1], tt.precision); err == nil {
+				t.Error("no error for truncated data")
+			}
+		})
+	}
+}
+
+func TestFloat16(t *testing.T) {
+	tests := []struct {
+		in   float32
+		want uint16
+	}{
+		{1, 0x3c00},
+		{-2, 0xc000},
+		{65504, 0x7bff},
+		{1e6, 0x7c00},                       // overflows to infinity
+		{float32(math.Inf(-1)), 0xfc00},     // negative infinity
+		{float32(math.Pow(2, -24)), 0x0001}, // smallest subnormal
+		{float32(math.Pow(2, -26)), 0x0000}, // underflows to zero
+		{1 + 1.0/2048, 0x3c00},              // halfway rounds to even
+		{1 + 3.0/2048, 0x3c02},              // halfway rounds to even
+	}
+	for _, tt := range tests {
+		if got := float32ToFloat16(tt.in); got != tt.want {
+			t.Errorf("float32ToFloat16(%v) = %#04x, want %#04x", tt.in, got, tt.want)
+		}
+	}
+	if got := float16ToFloat32(0x7e00); !math.IsNaN(float64(got)) {
+		t.Errorf("float16ToFloat32(NaN) = %v", got)
+	}
+}
+
+func TestEmbedResponseUnmarshal(t *testing.T) {
+	tests := []struct {
+		name string
+		body string
+		want [][]float64
+	}{
+		{"current", `{"model":"m","embeddings":[[1,2],[3,4]]}`, [][]float64{{1, 2}, {3, 4}}},
+		{"single vector under embeddings", `{"model":"m","embeddings":[1,2]}`, [][]float64{{1, 2}}},
+		{"legacy", `{"model":"m","embedding":[1,2]}`, [][]float64{{1, 2}}},
+		{"empty list falls back", `{"model":"m","embeddings":[],"embedding":[5]}`, [][]float64{{5}}},
+		{"none", `{"model":"m"}`, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var resp EmbedResponse
+			if err := json.Unmarshal([]byte(tt.body), &resp); err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(resp.Embeddings, tt.want) || resp.Model != "m" {
+				t.Errorf("EmbedResponse = %+v, want embeddings %v", resp, tt.want)
+			}
+		})
+	}
+
+	var bad EmbedResponse
+	if err := json.Unmarshal([]byte(`{"embeddings":"oops"}`), &bad); err == nil {
+		t.Error("no error for malformed embeddings")
+	}
+}
+
+func TestEmbeddingsResponseUnmarshal(t *testing.T) {
+	tests := []struct {
+		name string
+		body string
+		want []float64
+	}{
+		{"legacy", `{"embedding":[1,2]}`, []float64{1, 2}},
+		{"new", `{"embeddings":[[3,4],[5,6]]}`, []float64{3, 4}},
+		{"empty", `{"embedding":[]}`, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var resp EmbeddingsResponse
+			if err := json.Unmarshal([]byte(tt.body), &resp); err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(resp.Embedding, tt.want) {
+				t.Errorf("Embedding = %v, want %v", resp.Embedding, tt.want)
+			}
+		})
+	}
+
+	// An empty vector is an error from Embeddings rather than a silent nil
+	srv := routeServer(t, map[string]string{"/api/embeddings": `{"embedding":[]}`})
+	c := NewClient(WithBaseURL(srv.URL))
+	if _, err := c.Embeddings(context.Background(), EmbeddingsRequest{Model: "m", Prompt: "hi"}); !errors.Is(err, ErrEmptyResponse) {
+		t.Errorf("err = %v, want ErrEmptyResponse", err)
+	}
+}
+
+func TestEmbeddingValidation(t *testing.T) {
+	tests := []struct {
+		name       string
+		embeddings [][]float64
+		wantErr    string
+	}{
+		{"valid", [][]float64{{0.1, -0.2, 0.3}, {0.3, 0.2, 0.1}}, ""},
+		{"empty", [][]float64{{0.1, 0.2}, {}}, "1 (empty)"},
+		{"all zeros", [][]float64{{0, 0, 0}}, "0 (all zeros)"},
+		{"nan", [][]float64{{0.1, math.NaN()}}, "0 (not finite)"},
+		{"inf", [][]float64{{math.Inf(1), 0.1}}, "0 (not finite)"},
+		{"constant", [][]float64{{0.1, 0.2}, {0.5, 0.5, 0.5}}, "1 (constant)"},
+		{"mismatched dimensions", [][]float64{{0.1, 0.2, 0.3}, {0.1, 0.2}}, "1 (dimension 2, expected 3)"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resp := EmbedResponse{Embeddings: tt.embeddings}
+			err := resp.Validate()
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Errorf("err = %v", err)
+				}
+				return
+			}
+			if !errors.Is(err, ErrDegenerateEmbedding) || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("err = %v, want ErrDegenerateEmbedding for %s", err, tt.wantErr)
+			}
+		})
+	}
+
+	// Embed only validates when asked to
+	srv := embedServer(t, func(model, text string) []float64 { return []float64{0, 0} })
+	for _, enabled := range []bool{false, true} {
+		c := NewClient(WithBaseURL(srv.URL), WithEmbeddingValidation(enabled))
+		_, err := c.Embed(context.Background(), EmbedRequest{Model: "m", Input: []string{"hi"}})
+		if errors.Is(err, ErrDegenerateEmbedding) != enabled {
+			t.Errorf("validation %v: err = %v", enabled, err)
+		}
+	}
+}
+
+func TestEmbedBatches(t *testing.T) {
+	var batches []int
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req struct {
+			Model string   `json:"model"`
+			Input []string `json:"input"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			t.Errorf("decoding request: %v", err)
+		}
+		batches = append(batches, len(req.Input))
+		w.Header().Set("Content-Type", "application/json")
+		if slices.Contains(req.Input, "fail") {
+			w.WriteHeader(http.StatusBadRequest)
+			fmt.Fprint(w, `{"error":"bad input"}`)
+			return
+		}
+		embeddings := make([][]float64, len(req.Input))
+		for i, text := range req.Input {
+			embeddings[i] = []float64{float64(len(text)), 1}
+		}
+		json.NewEncoder(w).Encode(map[string]interface{}{"model": req.Model, "embeddings": embeddings, "prompt_eval_count": len(req.Input)})
+	}))
+	defer srv.Close()
+	c := NewClient(WithBaseURL(srv.URL))
+	ctx := context.Background()
+
+	type progress struct{ done, total int }
+	var got []progress
+	inputs := []string{"a", "bb", "ccc", "dddd", "eeeee"}
+	resp, err := c.EmbedBatches(ctx, EmbedRequest{Model: "m", Input: inputs}, 2, func(done, total int) {
+		got = append(got, progress{done, total})
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []progress{{2, 5}, {4, 5}, {5, 5}}; !slices.Equal(got, want) {
+		t.Errorf("progress = %v, want %v", got, want)
+	}
+	if want := []int{2, 2, 1}; !slices.Equal(batches, want) {
+		t.Errorf("batch sizes = %v, want %v", batches, want)
+	}
+	for i, v := range resp.Embeddings {
+		if v[0] != float64(i+1) {
+			t.Errorf("embedding %d = %v, out of input order", i, v)
+		}
+	}
+	if len(resp.Embeddings) != 5 || resp.PromptEvalCount != 5 {
+		t.Errorf("got %d embeddings and %d prompt tokens, want 5 of each", len(resp.Embeddings), resp.PromptEvalCount)
+	}
+
+	// A failed batch returns the batches before it
+	got = nil
+	resp, err = c.EmbedBatches(ctx, EmbedRequest{Model: "m", Input: []string{"a", "b", "fail"}}, 2, func(done, total int) {
+		got = append(got, progress{done, total})
+	})
+	if err == nil || !strings.Contains(err.Error(), "inputs 2 to 2") {
+		t.Errorf("err = %v, want the failed batch", err)
+	}
+	if len(resp.Embeddings) != 2 || !slices.Equal(got, []progress{{2, 3}}) {
+		t.Errorf("partial result has %d embeddings and progress %v", len(resp.Embeddings), got)
+	}
+
+	if _, err := c.EmbedBatches(ctx, EmbedRequest{Model: "m", Input: []string{}}, 2, nil); !errors.Is(err, ErrInvalidRequest) {
+		t.Errorf("err = %v, want ErrInvalidRequest for no input", err)
+	}
+}
@@ -75,30 +75,20 @@ func streamingGeneration(client *ollama.Client) error {
 
 	var fullResponse strings.Builder
 
-	// Handle streaming responses
-	for {
-		select {
-		case resp, ok := <-respChan:
-			if !ok {
-				if fullResponse.Len() > 0 {
-					return nil // Normal completion
-				}
-				return fmt.Errorf("response channel closed without data")
-			}
-			fullResponse.WriteString(resp.Response)
-			fmt.Print(resp.Response)
-			if resp.Done {
-				fmt.Println("\n\nGeneration complete!")
-				return nil
-			}
-		case err := <-errChan:
-			if err != nil {
-				return fmt.Errorf("streaming error: %w", err)
-			}
-		case <-ctx.Done():
-			return fmt.Errorf("generation timed out: %w", ctx.Err())
-		}
+	// Handle streaming responses. The error channel carries the terminal
+	// error once the response channel is closed.
+	for resp := range respChan {
+		fullResponse.WriteString(resp.Response)
+		fmt.Print(resp.Response)
+	}
+	if err := <-errChan; err != nil {
+		return fmt.Errorf("streaming error: %w", err)
 	}
+	if fullResponse.Len() == 0 {
+		return fmt.Errorf("stream ended without data")
+	}
+	fmt.Println("\n\nGeneration complete!")
+	return nil
 }
 
 func chatExample(client *ollama.Client) error {
@@ -0,0 +1,127 @@
+// file.go
+package ollamago
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"slices"
+)
+
+// GenerationRecord is the metadata GenerateToFile writes next to the
+// generated text, enough to continue the generation later
+type GenerationRecord struct {
+	Request GenerateRequest `json:"request"`
+	// Response holds the final metadata, including Context and the token
+	// counts; its text is in the output file
+	Response GenerateResponse `json:"response"`
+	// Partial is set when the generation stopped before completing
+	Partial bool   `json:"partial,omitempty"`
+	Error   string `json:"error,omitempty"`
+}
+
+// RecordPath returns the path of the metadata file GenerateToFile writes
+// for an output file
+func RecordPath(path string) string {
+	return path + ".meta.json"
+}
+
+// GenerateToFile streams a completion into the file at path and writes a
+// GenerationRecord to RecordPath(path). When the stream fails or the context
+// is cancelled, the text received so far is kept, the record is marked
+// partial, and the accumulated response is returned with the error.
+func (c *Client) GenerateToFile(ctx context.Context, req GenerateRequest, path string) (*GenerateResponse, error) {
+	f, err := os.Create(path)
+	if err != nil {
+		return nil, fmt.Errorf("creating output file: %w", err)
+	}
+
+	var acc generateAccumulator
+	streamErr := c.GenerateFunc(ctx, req, func(chunk GenerateResponse) error {
+		if _, err := f.WriteString(chunk.Response); err != nil {
+			return fmt.Errorf("writing output file: %w", err)
+		}
+		acc.add(chunk)
+		return nil
+	})
+	if err := f.Close(); err != nil && streamErr == nil {
+		streamErr = fmt.Errorf("writing output file: %w", err)
+	}
+	resp := acc.result()
+
+	record := GenerationRecord{
+		Request:  req,
+		Response: *resp,
+		Partial:  streamErr != nil || resp.Partial,
+	}
+	record.Response.Response = ""
+	if streamErr != nil {
+		record.Error = streamErr.Error()
+	}
+	recordErr := writeRecord(RecordPath(path), &record)
+
+	return resp, errors.Join(streamErr, recordErr)
+}
+
+// writeRecord writes a generation record as indented JSON
+func writeRecord(path string, record *GenerationRecord) error {
+	b, err := json.MarshalIndent(record, "", "  ")
+	if err != nil {
+		return fmt.Errorf("encoding generation record: %w", err)
+	}
+	if err := os.WriteFile(path, b, 0o644); err != nil {
+		return fmt.Errorf("writing generation record: %w", err)
+	}
+	return nil
+}
+
+// ReadGenerationRecord reads the record GenerateToFile wrote for the output
+// file at path
+func ReadGenerationRecord(path string) (*GenerationRecord, error) {
+	b, err := os.ReadFile(RecordPath(path))
+	if err != nil {
+		return nil, fmt.Errorf("reading generation record: %w", err)
+	}
+
+	var record GenerationRecord
+	if err := json.Unmarshal(b, &record); err != nil {
+		return nil, fmt.Errorf("decoding generation record: %w", err)
+	}
+	return &record, nil
+}
+
+// Continue returns a request that continues the recorded generation with a
+// new prompt, using the saved context
+func (r *GenerationRecord) Continue(prompt string) GenerateRequest {
+	req := r.Request.clone()
+	req.Prompt = prompt
+	req.Context = slices.Clone(r.Response.Context)
+	return req
+}
+
+// DefaultMaxPromptSize is the default maximum number of bytes
+// GenerateFromReader reads
+const DefaultMaxPromptSize = 8 << 20
+
+// GenerateFromReader reads a whole prompt from r, such as a file or stdin,
+// and generates a completion for it. Prompts longer than the client's
+// maximum prompt size, set with WithMaxPromptSize, are rejected with a
+// RequestError rather than truncated.
+func (c *Client) GenerateFromReader(ctx context.Context, model string, r io.Reader, opts *Options) (*GenerateResponse, error) {
+	b, err := io.ReadAll(io.LimitReader(r, c.maxPrompt+1))
+	if err != nil {
+		return nil, fmt.Errorf("reading prompt: %w", err)
+	}
+	if int64(len(b)) > c.maxPrompt {
+		return nil, &RequestError{Message: fmt.Sprintf("prompt exceeds %d bytes", c.maxPrompt)}
+	}
+
+	return c.Generate(ctx, GenerateRequest{
+		Model:   model,
+		Prompt:  string(b),
+		Options: opts,
+	})
+}
@@ -0,0 +1,116 @@
+// file_test.go
+package ollamago
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestGenerateToFileReadBack(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/x-ndjson")
+		fmt.Fprintln(w, `{"model":"m","response":"Hello, "}`)
+		fmt.Fprintln(w, `{"model":"m","response":"world"}`)
+		fmt.Fprintln(w, `{"model":"m","response":"","done":true,"context":[1,2,3],"eval_count":2}`)
+	}))
+	defer srv.Close()
+
+	tests := []struct {
+		name   string
+		req    GenerateRequest
+		format string
+		schema string
+	}{
+		{"plain", GenerateRequest{Model: "m", Prompt: "hi"}, "", ""},
+		{"json", GenerateRequest{Model: "m", Prompt: "hi", Format: "json"}, "json", ""},
+		{"schema", GenerateRequest{Model: "m", Prompt: "hi", FormatSchema: json.RawMessage(`{"type":"object"}`)}, "", `{"type":"object"}`},
+		{"schema string", GenerateRequest{Model: "m", Prompt: "hi", Format: `{"type":"object"}`}, "", `{"type":"object"}`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := NewClient(WithBaseURL(srv.URL))
+			path := filepath.Join(t.TempDir(), "out.txt")
+
+			resp, err := c.GenerateToFile(context.Background(), tt.req, path)
+			if err != nil {
+				t.Fatalf("GenerateToFile: %v", err)
+			}
+			if resp.Response != "Hello, world" {
+				t.Errorf("response = %q, want %q", resp.Response, "Hello, world")
+			}
+
+			record, err := ReadGenerationRecord(path)
+			if err != nil {
+				t.Fatalf("ReadGenerationRecord: %v", err)
+			}
+			if record.Partial {
+				t.Error("record is partial")
+			}
+			if !slices.Equal(record.Response.Context, []int{1, 2, 3}) {
+				t.Errorf("context = %v, want [1 2 3]", record.Response.Context)
+			}
+
+			next := record.Continue("more")
+			if next.Prompt != "more" || !slices.Equal(next.Context, []int{1, 2, 3}) {
+				t.Errorf("Continue = %+v", next)
+			}
+			if next.Format != tt.format {
+				t.Errorf("format = %q, want %q", next.Format, tt.format)
+			}
+			var schema bytes.Buffer
+			if len(next.FormatSchema) > 0 {
+				if err := json.Compact(&schema, next.FormatSchema); err != nil {
+					t.Fatalf("format schema: %v", err)
+				}
+			}
+			if schema.String() != tt.schema {
+				t.Errorf("format schema = %s, want %s", schema.String(), tt.schema)
+			}
+		})
+	}
+}
+
+func TestGenerateFromReader(t *testing.T) {
+	var prompts []string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req GenerateRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			t.Errorf("decoding request: %v", err)
+		}
+		prompts = append(prompts, req.Prompt)
+		w.Header().Set("Content-Type", "application/json")
+		fmt.Fprint(w, `{"response":"ok","done":true}`)
+	}))
+	defer srv.Close()
+	c := NewClient(WithBaseURL(srv.URL), WithMaxPromptSize(16))
+	ctx := context.Background()
+
+	resp, err := c.GenerateFromReader(ctx, "m", bytes.NewBufferString("Summarize this."), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.Response != "ok" || !slices.Equal(prompts, []string{"Summarize this."}) {
+		t.Errorf("response %q, prompts sent %q", resp.Response, prompts)
+	}
+
+	// Exactly at the limit is accepted, one byte over is not sent
+	if _, err := c.GenerateFromReader(ctx, "m", bytes.NewBufferString(strings.Repeat("a", 16)), nil); err != nil {
+		t.Errorf("prompt at the limit: %v", err)
+	}
+	_, err = c.GenerateFromReader(ctx, "m", bytes.NewBufferString(strings.Repeat("a", 17)), nil)
+	if !errors.Is(err, ErrInvalidRequest) {
+		t.Errorf("err = %v, want ErrInvalidRequest", err)
+	}
+	if len(prompts) != 2 {
+		t.Errorf("%d requests sent, want the over-limit prompt rejected", len(prompts))
+	}
+}
@@ -0,0 +1,80 @@
+// generation.go
+package ollamago
+
+import (
+	"context"
+	"sync"
+)
+
+// Generation is a streaming generation running in the background that can
+// be inspected and stopped while it runs, for "stop generating" controls
+type Generation struct {
+	cancel context.CancelFunc
+	done   chan struct{}
+
+	mu  sync.Mutex
+	acc generateAccumulator
+	err error
+}
+
+// StartGenerate starts streaming a completion in the background
+func (c *Client) StartGenerate(ctx context.Context, req GenerateRequest) *Generation {
+	ctx, cancel := context.WithCancel(ctx)
+	g := &Generation{cancel: cancel, done: make(chan struct{})}
+
+	go func() {
+		defer close(g.done)
+		defer cancel()
+
+		err := c.GenerateFunc(ctx, req, func(chunk GenerateResponse) error {
+			g.mu.Lock()
+			g.acc.add(chunk)
+			g.mu.Unlock()
+			return nil
+		})
+		g.mu.Lock()
+		g.err = err
+		g.mu.Unlock()
+	}()
+
+	return g
+}
+
+// Snapshot returns the text generated so far. The response is partial until
+// the generation is done.
+func (g *Generation) Snapshot() *GenerateResponse {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	return g.acc.result()
+}
+
+// Wait waits for the generation to end and returns the response. When the
+// generation was stopped or failed, the response holds the text produced up
+// to that point, with Partial set, alongside the error.
+func (g *Generation) Wait() (*GenerateResponse, error) {
+	<-g.done
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	return g.acc.result(), g.err
+}
+
+// Stop cancels the generation and returns what was produced up to the stop.
+// Stopping a generation that already finished returns its full response.
+func (g *Generation) Stop() *GenerateResponse {
+	g.cancel()
+	resp, _ := g.Wait()
+	return resp
+}
+
+// Done returns a channel closed when the generation ends
+func (g *Generation) Done() <-chan struct{} {
+	return g.done
+}
+
+// Err returns the error the generation ended with, or nil while it runs
+// and after it completes
+func (g *Generation) Err() error {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	return g.err
+}
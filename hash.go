@@ -0,0 +1,73 @@
+// hash.go
+package ollamago
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"strconv"
+)
+
+// RequestHash returns a stable SHA-256 hex digest of a request, suitable as a
+// cache or deduplication key. The request is canonicalized first: object keys
+// are sorted, null values and empty objects are dropped, and numbers are
+// normalized, so equivalent requests hash equally.
+func RequestHash(req any) (string, error) {
+	b, err := json.Marshal(req)
+	if err != nil {
+		return "", fmt.Errorf("marshaling request: %w", err)
+	}
+
+	dec := json.NewDecoder(bytes.NewReader(b))
+	dec.UseNumber()
+	var v interface{}
+	if err := dec.Decode(&v); err != nil {
+		return "", fmt.Errorf("decoding request: %w", err)
+	}
+
+	// encoding/json writes map keys in sorted order
+	canonical, err := json.Marshal(canonicalize(v))
+	if err != nil {
+		return "", fmt.Errorf("marshaling canonical request: %w", err)
+	}
+
+	sum := sha256.Sum256(canonical)
+	return hex.EncodeToString(sum[:]), nil
+}
+
+// canonicalize normalizes a decoded JSON value
+func canonicalize(v interface{}) interface{} {
+	switch v := v.(type) {
+	case map[string]interface{}:
+		out := make(map[string]interface{}, len(v))
+		for key, value := range v {
+			value = canonicalize(value)
+			if value == nil {
+				continue
+			}
+			if m, ok := value.(map[string]interface{}); ok && len(m) == 0 {
+				continue
+			}
+			out[key] = value
+		}
+		return out
+	case []interface{}:
+		out := make([]interface{}, len(v))
+		for i, value := range v {
+			out[i] = canonicalize(value)
+		}
+		return out
+	case json.Number:
+		if i, err := strconv.ParseInt(string(v), 10, 64); err == nil {
+			return json.Number(strconv.FormatInt(i, 10))
+		}
+		if f, err := strconv.ParseFloat(string(v), 64); err == nil {
+			return json.Number(strconv.FormatFloat(f, 'g', -1, 64))
+		}
+		return v
+	default:
+		return v
+	}
+}
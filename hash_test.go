@@ -0,0 +1,59 @@
+// hash_test.go
+package ollamago
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func TestRequestHash(t *testing.T) {
+	tests := []struct {
+		name string
+		a, b any
+		same bool
+	}{
+		{
+			name: "key order",
+			a:    json.RawMessage(`{"model":"m","prompt":"hi"}`),
+			b:    json.RawMessage(`{"prompt":"hi","model":"m"}`),
+			same: true,
+		},
+		{
+			name: "null fields",
+			a:    json.RawMessage(`{"model":"m","system":null,"options":{}}`),
+			b:    json.RawMessage(`{"model":"m"}`),
+			same: true,
+		},
+		{
+			name: "integral float",
+			a:    json.RawMessage(`{"model":"m","options":{"temperature":1}}`),
+			b:    json.RawMessage(`{"model":"m","options":{"temperature":1.0}}`),
+			same: true,
+		},
+		{
+			name: "changed prompt",
+			a:    GenerateRequest{Model: "m", Prompt: "hi"},
+			b:    GenerateRequest{Model: "m", Prompt: "hello"},
+		},
+		{
+			name: "changed option",
+			a:    GenerateRequest{Model: "m", Options: &Options{Temperature: ptr(0.7)}},
+			b:    GenerateRequest{Model: "m", Options: &Options{Temperature: ptr(0.8)}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			a, err := RequestHash(tt.a)
+			if err != nil {
+				t.Fatal(err)
+			}
+			b, err := RequestHash(tt.b)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if (a == b) != tt.same {
+				t.Errorf("hashes %s and %s, want same: %v", a, b, tt.same)
+			}
+		})
+	}
+}
@@ -0,0 +1,48 @@
+// health.go
+package ollamago
+
+import (
+	"context"
+	"errors"
+	"fmt"
+)
+
+// HealthReport summarizes the state of an Ollama server
+type HealthReport struct {
+	ServerVersion string
+	RunningModels []RunningModel
+	TotalVRAM     int64
+	LocalModels   int
+}
+
+// HealthReport combines the server version, the models loaded into memory,
+// and the number of local models into a single report. When some endpoints
+// fail the report still holds what succeeded, and the returned error
+// describes the failures.
+func (c *Client) HealthReport(ctx context.Context) (*HealthReport, error) {
+	report := &HealthReport{}
+	var errs []error
+
+	if version, err := c.Version(ctx); err != nil {
+		errs = append(errs, fmt.Errorf("version: %w", err))
+	} else {
+		report.ServerVersion = version.Version
+	}
+
+	if running, err := c.ListRunningModels(ctx); err != nil {
+		errs = append(errs, fmt.Errorf("running models: %w", err))
+	} else {
+		report.RunningModels = running.Models
+		for _, m := range running.Models {
+			report.TotalVRAM += m.SizeVRAM
+		}
+	}
+
+	if models, err := c.ListModels(ctx); err != nil {
+		errs = append(errs, fmt.Errorf("local models: %w", err))
+	} else {
+		report.LocalModels = len(models.Models)
+	}
+
+	return report, errors.Join(errs...)
+}
@@ -0,0 +1,79 @@
+// health_test.go
+package ollamago
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// routeServer answers each path with a fixed JSON body. Other paths fail
+// with a server error.
+func routeServer(t *testing.T, routes map[string]string) *httptest.Server {
+	t.Helper()
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		body, ok := routes[r.URL.Path]
+		if !ok {
+			w.WriteHeader(http.StatusInternalServerError)
+			fmt.Fprint(w, `{"error":"unavailable"}`)
+			return
+		}
+		fmt.Fprint(w, body)
+	}))
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+func TestHealthReport(t *testing.T) {
+	const (
+		version = `{"version":"0.5.1"}`
+		ps      = `{"models":[{"name":"a","size_vram":100},{"name":"b","size_vram":50}]}`
+		tags    = `{"models":[{"name":"a"},{"name":"b"},{"name":"c"}]}`
+	)
+
+	t.Run("success", func(t *testing.T) {
+		srv := routeServer(t, map[string]string{"/api/version": version, "/api/ps": ps, "/api/tags": tags})
+		c := NewClient(WithBaseURL(srv.URL))
+
+		report, err := c.HealthReport(context.Background())
+		if err != nil {
+			t.Fatal(err)
+		}
+		if report.ServerVersion != "0.5.1" || len(report.RunningModels) != 2 || report.TotalVRAM != 150 || report.LocalModels != 3 {
+			t.Errorf("report = %+v", report)
+		}
+	})
+
+	t.Run("partial failure", func(t *testing.T) {
+		srv := routeServer(t, map[string]string{"/api/version": version, "/api/tags": tags})
+		c := NewClient(WithBaseURL(srv.URL))
+
+		report, err := c.HealthReport(context.Background())
+		if err == nil || !strings.Contains(err.Error(), "running models") {
+			t.Fatalf("err = %v, want the running models failure", err)
+		}
+		if strings.Contains(err.Error(), "version:") || strings.Contains(err.Error(), "local models") {
+			t.Errorf("err = %v, reports endpoints that succeeded", err)
+		}
+		if report.ServerVersion != "0.5.1" || report.LocalModels != 3 || report.RunningModels != nil {
+			t.Errorf("report = %+v, want the successful parts filled", report)
+		}
+	})
+
+	t.Run("all fail", func(t *testing.T) {
+		srv := routeServer(t, nil)
+		c := NewClient(WithBaseURL(srv.URL))
+
+		_, err := c.HealthReport(context.Background())
+		if err == nil {
+			t.Fatal("no error")
+		}
+		if n := len(err.(interface{ Unwrap() []error }).Unwrap()); n != 3 {
+			t.Errorf("joined %d errors, want 3", n)
+		}
+	})
+}
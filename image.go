@@ -0,0 +1,58 @@
+// image.go
+package ollamago
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+)
+
+// ImageFromBytes builds an Image from raw image bytes, base64 encoding them
+// as the API expects
+func ImageFromBytes(b []byte) Image {
+	return Image{Data: base64.StdEncoding.EncodeToString(b)}
+}
+
+// ImageFromFile reads an image file and builds an Image from it. Files whose
+// content is not recognized as an image are rejected.
+func ImageFromFile(path string) (Image, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return Image{}, fmt.Errorf("reading image: %w", err)
+	}
+	if len(b) == 0 {
+		return Image{}, fmt.Errorf("reading image: %s is empty", path)
+	}
+
+	if contentType := http.DetectContentType(b); !strings.HasPrefix(contentType, "image/") {
+		return Image{}, fmt.Errorf("%s is not an image (detected %s)", path, contentType)
+	}
+
+	return ImageFromBytes(b), nil
+}
+
+// MarshalJSON encodes the image as the bare base64 string the API expects
+func (i Image) MarshalJSON() ([]byte, error) {
+	return json.Marshal(i.Data)
+}
+
+// UnmarshalJSON accepts either a base64 string or an object with a data field
+func (i *Image) UnmarshalJSON(b []byte) error {
+	var s string
+	if err := json.Unmarshal(b, &s); err == nil {
+		i.Data = s
+		return nil
+	}
+
+	var obj struct {
+		Data string `json:"data"`
+	}
+	if err := json.Unmarshal(b, &obj); err != nil {
+		return err
+	}
+	i.Data = obj.Data
+	return nil
+}
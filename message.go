@@ -0,0 +1,71 @@
+// message.go
+package ollamago
+
+import (
+	"encoding/base64"
+	"fmt"
+	"strings"
+)
+
+// UserMessage builds a user message with optional images for vision models
+func UserMessage(text string, images ...Image) Message {
+	return Message{Role: "user", Content: text, Images: images}
+}
+
+// NewImageMessage builds a user message asking about one or more images. It
+// fails when no image is given or one is not valid base64 data.
+func NewImageMessage(text string, images ...Image) (Message, error) {
+	if len(images) == 0 {
+		return Message{}, &RequestError{Message: "image message needs at least one image"}
+	}
+	msg := UserMessage(text, images...)
+	if err := msg.Validate(); err != nil {
+		return Message{}, err
+	}
+	return msg, nil
+}
+
+// MustImageFromFile is like ImageFromFile but panics on error. It is meant
+// for images bundled with a program, such as in
+// UserMessage("what's this?", MustImageFromFile("cat.png")).
+func MustImageFromFile(path string) Image {
+	img, err := ImageFromFile(path)
+	if err != nil {
+		panic(err)
+	}
+	return img
+}
+
+// Validate checks that the message's role is known and that its parts are
+// allowed for that role: images only on user messages, tool calls only on
+// assistant messages, and some content in every message but an assistant
+// one that only calls tools
+func (m Message) Validate() error {
+	switch m.Role {
+	case "system", "user", "assistant", "tool":
+	case "":
+		return &RequestError{Message: "message role is required"}
+	default:
+		return &RequestError{Message: fmt.Sprintf("unknown message role %q", m.Role)}
+	}
+
+	if len(m.Images) > 0 && m.Role != "user" {
+		return &RequestError{Message: fmt.Sprintf("%s messages cannot have images", m.Role)}
+	}
+	if len(m.ToolCalls) > 0 && m.Role != "assistant" {
+		return &RequestError{Message: fmt.Sprintf("%s messages cannot have tool calls", m.Role)}
+	}
+	if strings.TrimSpace(m.Content) == "" && len(m.Images) == 0 && len(m.ToolCalls) == 0 {
+		return &RequestError{Message: fmt.Sprintf("%s message has no content", m.Role)}
+	}
+
+	for i, img := range m.Images {
+		if img.Data == "" {
+			return &RequestError{Message: fmt.Sprintf("image %d is empty", i)}
+		}
+		if _, err := base64.StdEncoding.DecodeString(img.Data); err != nil {
+			return &RequestError{Message: fmt.Sprintf("image %d is not valid base64: %v", i, err)}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,146 @@
+// meter.go
+package ollamago
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// DefaultMeterWindow is the default period over which a TokenMeter
+// averages its rate
+const DefaultMeterWindow = 5 * time.Second
+
+// DefaultMeterInterval is the interval Subscribe uses when given one that
+// is not positive
+const DefaultMeterInterval = time.Second
+
+// TokenMeter measures the combined generation rate of all streams of the
+// clients it is attached to with WithTokenMeter. Every streamed chunk counts
+// as one token, which is how the server streams. It is safe for concurrent
+// use.
+type TokenMeter struct {
+	window time.Duration
+
+	mu     sync.Mutex
+	events []time.Time
+	total  int64
+	active int
+}
+
+// MeterReading is a snapshot of a TokenMeter
+type MeterReading struct {
+	// TokensPerSecond is the rate over the meter's window
+	TokensPerSecond float64
+	// ActiveStreams is the number of streams in progress
+	ActiveStreams int
+	// TotalTokens counts every token since the meter was created
+	TotalTokens int64
+}
+
+// NewTokenMeter creates a meter averaging over window, or
+// DefaultMeterWindow when window is not positive
+func NewTokenMeter(window time.Duration) *TokenMeter {
+	if window <= 0 {
+		window = DefaultMeterWindow
+	}
+	return &TokenMeter{window: window}
+}
+
+// WithTokenMeter reports the chunks of every GenerateStream and ChatStream
+// to meter. One meter may be shared by several clients.
+func WithTokenMeter(meter *TokenMeter) Option {
+	return func(c *Client) {
+		c.meter = meter
+	}
+}
+
+// start records that a stream began. A nil meter records nothing.
+func (m *TokenMeter) start() {
+	if m == nil {
+		return
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.active++
+}
+
+// end records that a stream finished
+func (m *TokenMeter) end() {
+	if m == nil {
+		return
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.active--
+}
+
+// record counts a token received at now
+func (m *TokenMeter) record(now time.Time) {
+	if m == nil {
+		return
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.events = append(m.events, now)
+	m.total++
+	m.prune(now)
+}
+
+// prune drops the events that fell out of the window
+func (m *TokenMeter) prune(now time.Time) {
+	cutoff := now.Add(-m.window)
+	i := 0
+	for i < len(m.events) && !m.events[i].After(cutoff) {
+		i++
+	}
+	if i > 0 {
+		m.events = append(m.events[:0], m.events[i:]...)
+	}
+}
+
+// Reading returns the current rate and counts
+func (m *TokenMeter) Reading() MeterReading {
+	return m.readingAt(time.Now())
+}
+
+func (m *TokenMeter) readingAt(now time.Time) MeterReading {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.prune(now)
+	return MeterReading{
+		TokensPerSecond: float64(len(m.events)) / m.window.Seconds(),
+		ActiveStreams:   m.active,
+		TotalTokens:     m.total,
+	}
+}
+
+// Subscribe sends a reading every interval, or DefaultMeterInterval when
+// interval is not positive, until ctx is done, then closes the channel.
+// Readings are dropped while the receiver is not ready.
+func (m *TokenMeter) Subscribe(ctx context.Context, interval time.Duration) <-chan MeterReading {
+	if interval <= 0 {
+		interval = DefaultMeterInterval
+	}
+	out := make(chan MeterReading, 1)
+
+	go func() {
+		defer close(out)
+
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case now := <-ticker.C:
+				select {
+				case out <- m.readingAt(now):
+				default:
+				}
+			}
+		}
+	}()
+
+	return out
+}
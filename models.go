@@ -0,0 +1,515 @@
+// models.go
+package ollamago
+
+import (
+	"cmp"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"path"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// normalizeModelName adds the implicit ":latest" tag to a model name
+func normalizeModelName(name string) string {
+	if i := strings.LastIndex(name, ":"); i == -1 || strings.Contains(name[i:], "/") {
+		return name + ":latest"
+	}
+	return name
+}
+
+// DefaultRegistry is the registry that model names without one refer to
+const DefaultRegistry = "registry.ollama.ai"
+
+// CanonicalModelName returns the short form of a model name, so that names
+// differing only by the default registry, the library namespace or the
+// implicit latest tag compare equal: "registry.ollama.ai/library/llama3"
+// and "llama3" both become "llama3:latest". Names on other registries keep
+// their registry.
+func CanonicalModelName(name string) string {
+	name = strings.TrimPrefix(name, DefaultRegistry+"/")
+	name = strings.TrimPrefix(name, "library/")
+	return normalizeModelName(name)
+}
+
+// ModelGroup is a set of listed models that share a canonical name
+type ModelGroup struct {
+	// Name is the canonical name, see CanonicalModelName
+	Name   string
+	Models []ModelInfo
+}
+
+// GroupByModel groups the listed models by canonical name, so that a model
+// listed both with and without the default registry prefix is shown once.
+// Groups are in the order their first model was listed.
+func (r *ListModelsResponse) GroupByModel() []ModelGroup {
+	var groups []ModelGroup
+	index := make(map[string]int)
+	for _, m := range r.Models {
+		name := CanonicalModelName(m.Name)
+		i, ok := index[name]
+		if !ok {
+			i = len(groups)
+			index[name] = i
+			groups = append(groups, ModelGroup{Name: name})
+		}
+		groups[i].Models = append(groups[i].Models, m)
+	}
+	return groups
+}
+
+// ResolveAlias returns the canonical name of a model that may be a copy of
+// another. Models sharing the same digest are copies of each other, and the
+// canonical one is the oldest, with ties broken by name.
+func (c *Client) ResolveAlias(ctx context.Context, name string) (string, error) {
+	if name == "" {
+		return "", &RequestError{Message: "model name is required"}
+	}
+
+	models, err := c.ListModels(ctx)
+	if err != nil {
+		return "", err
+	}
+
+	target := normalizeModelName(name)
+	var match *ModelInfo
+	for i := range models.Models {
+		if normalizeModelName(models.Models[i].Name) == target {
+			match = &models.Models[i]
+			break
+		}
+	}
+	if match == nil {
+		return "", fmt.Errorf("model %q not found", name)
+	}
+	if match.Digest == "" {
+		return match.Name, nil
+	}
+
+	canonical := match
+	for i := range models.Models {
+		m := &models.Models[i]
+		if m.Digest != match.Digest {
+			continue
+		}
+		if m.ModifiedAt.Before(canonical.ModifiedAt) ||
+			(m.ModifiedAt.Equal(canonical.ModifiedAt) && m.Name < canonical.Name) {
+			canonical = m
+		}
+	}
+
+	return canonical.Name, nil
+}
+
+// parseParameters parses a Modelfile parameters block, where each line holds
+// a parameter name followed by a possibly quoted value. Repeated names such
+// as stop collect every value in order.
+func parseParameters(block string) map[string][]string {
+	params := make(map[string][]string)
+	for _, line := range strings.Split(block, "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" {
+			continue
+		}
+
+		key, value := line, ""
+		if i := strings.IndexAny(line, " \t"); i != -1 {
+			key, value = line[:i], strings.TrimSpace(line[i+1:])
+		}
+		if len(value) >= 2 && value[0] == '"' && value[len(value)-1] == '"' {
+			if unquoted, err := strconv.Unquote(value); err == nil {
+				value = unquoted
+			} else {
+				value = value[1 : len(value)-1]
+			}
+		}
+		params[key] = append(params[key], value)
+	}
+	return params
+}
+
+// ParsedParameters returns the model's parameters keyed by name. Quoted
+// values are unquoted, and repeated parameters such as stop keep every value.
+func (r *ShowModelResponse) ParsedParameters() map[string][]string {
+	return parseParameters(r.Parameters)
+}
+
+// RecommendedOptions returns the parameters set in a model's Modelfile as
+// Options, so the model author's settings can be used as defaults.
+// Parameters that Options does not know about are ignored.
+func (c *Client) RecommendedOptions(ctx context.Context, model string) (*Options, error) {
+	info, err := c.ShowModel(ctx, ShowModelRequest{Name: model})
+	if err != nil {
+		return nil, err
+	}
+	return optionsFromParameters(info.ParsedParameters())
+}
+
+// optionsFromParameters converts parsed parameters into Options using the
+// JSON names of the Options fields
+func optionsFromParameters(params map[string][]string) (*Options, error) {
+	var opts Options
+	for key, values := range params {
+		var value interface{}
+		if key == "stop" {
+			value = values
+		} else {
+			value = parseParameterValue(values[len(values)-1])
+		}
+
+		b, err := json.Marshal(map[string]interface{}{key: value})
+		if err != nil {
+			return nil, fmt.Errorf("parameter %s: %w", key, err)
+		}
+		// Unmarshal into the same struct so each parameter is set in turn
+		if err := json.Unmarshal(b, &opts); err != nil {
+			return nil, fmt.Errorf("parameter %s: invalid value %q", key, values[len(values)-1])
+		}
+	}
+	return &opts, nil
+}
+
+// parseParameterValue converts a parameter value to the JSON type it most
+// likely represents
+func parseParameterValue(s string) interface{} {
+	if i, err := strconv.ParseInt(s, 10, 64); err == nil {
+		return i
+	}
+	if f, err := strconv.ParseFloat(s, 64); err == nil {
+		return f
+	}
+	if b, err := strconv.ParseBool(s); err == nil {
+		return b
+	}
+	return s
+}
+
+// UnloadModel evicts a model from memory immediately, freeing the memory it
+// uses. It sends an empty generate request with a zero keep-alive.
+func (c *Client) UnloadModel(ctx context.Context, model string) error {
+	if model == "" {
+		return &RequestError{Message: "model is required"}
+	}
+	req := GenerateRequest{Model: model, KeepAlive: "0"}
+	return c.request(ctx, http.MethodPost, "/api/generate", req, nil, false)
+}
+
+// PreloadModel loads a model into memory without generating anything, so
+// the first real request does not pay the load time. The model stays loaded
+// for the keep-alive set with WithKeepAlive, or the server's default.
+func (c *Client) PreloadModel(ctx context.Context, model string) error {
+	if model == "" {
+		return &RequestError{Message: "model is required"}
+	}
+	req := GenerateRequest{Model: model}
+	c.applyKeepAlive(&req.KeepAlive)
+	return c.request(ctx, http.MethodPost, "/api/generate", req, nil, false)
+}
+
+// UnloadAll evicts every model currently loaded into memory. Each model is
+// attempted even when others fail, and the failures are returned together.
+func (c *Client) UnloadAll(ctx context.Context) error {
+	running, err := c.ListRunningModels(ctx)
+	if err != nil {
+		return err
+	}
+
+	var errs []error
+	for _, m := range running.Models {
+		name := m.Model
+		if name == "" {
+			name = m.Name
+		}
+		if err := c.UnloadModel(ctx, name); err != nil {
+			errs = append(errs, fmt.Errorf("unloading %s: %w", name, err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// ModelSortKey selects the order of ListModelsFiltered
+type ModelSortKey int
+
+const (
+	// SortNone keeps the server's order
+	SortNone ModelSortKey = iota
+	SortByName
+	SortBySize
+	SortByModified
+)
+
+// ListModelsOptions filters and orders the models of ListModelsFiltered
+type ListModelsOptions struct {
+	// Name matches model names by substring, or as a path.Match glob when
+	// it contains *, ? or [
+	Name string
+	// Family matches the model's family or any of its families
+	Family string
+	SortBy ModelSortKey
+	// Descending reverses the order
+	Descending bool
+}
+
+// ListModelsFiltered lists the local models matching opts, in the order it
+// asks for. Filtering and sorting happen on the client.
+func (c *Client) ListModelsFiltered(ctx context.Context, opts ListModelsOptions) ([]ModelInfo, error) {
+	resp, err := c.ListModels(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	models := make([]ModelInfo, 0, len(resp.Models))
+	for _, m := range resp.Models {
+		ok, err := matchModel(m, opts)
+		if err != nil {
+			return nil, err
+		}
+		if ok {
+			models = append(models, m)
+		}
+	}
+
+	var less func(a, b ModelInfo) int
+	switch opts.SortBy {
+	case SortByName:
+		less = func(a, b ModelInfo) int { return strings.Compare(a.Name, b.Name) }
+	case SortBySize:
+		less = func(a, b ModelInfo) int { return cmp.Compare(a.Size, b.Size) }
+	case SortByModified:
+		less = func(a, b ModelInfo) int { return a.ModifiedAt.Compare(b.ModifiedAt) }
+	}
+	if less != nil {
+		slices.SortStableFunc(models, func(a, b ModelInfo) int {
+			if opts.Descending {
+				return less(b, a)
+			}
+			return less(a, b)
+		})
+	} else if opts.Descending {
+		slices.Reverse(models)
+	}
+	return models, nil
+}
+
+// matchModel reports whether a model passes the filters of opts
+func matchModel(m ModelInfo, opts ListModelsOptions) (bool, error) {
+	if opts.Name != "" {
+		if strings.ContainsAny(opts.Name, "*?[") {
+			ok, err := path.Match(opts.Name, m.Name)
+			if err != nil {
+				return false, &RequestError{Message: fmt.Sprintf("invalid name pattern %q: %v", opts.Name, err)}
+			}
+			if !ok {
+				return false, nil
+			}
+		} else if !strings.Contains(m.Name, opts.Name) {
+			return false, nil
+		}
+	}
+	if opts.Family != "" && m.Details.Family != opts.Family && !slices.Contains(m.Details.Families, opts.Family) {
+		return false, nil
+	}
+	return true, nil
+}
+
+// ModelExists reports whether a model is available locally. A name without
+// a tag matches the latest tag.
+func (c *Client) ModelExists(ctx context.Context, name string) (bool, error) {
+	if name == "" {
+		return false, &RequestError{Message: "model name is required"}
+	}
+
+	resp, err := c.ListModels(ctx)
+	if err != nil {
+		return false, err
+	}
+	target := normalizeModelName(name)
+	for _, m := range resp.Models {
+		if normalizeModelName(m.Name) == target {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// ModelFingerprint returns a stable hash of what determines a model's
+// behavior: its digest, template, system prompt and parameters. It changes
+// when a re-pulled tag behaves differently, so it suits as a cache key. It
+// fails with ErrModelNotFound when the model is not in the local list, as
+// the fingerprint would otherwise miss the digest.
+func (c *Client) ModelFingerprint(ctx context.Context, name string) (string, error) {
+	if name == "" {
+		return "", &RequestError{Message: "model name is required"}
+	}
+
+	info, err := c.ShowModel(ctx, ShowModelRequest{Name: name})
+	if err != nil {
+		return "", err
+	}
+	models, err := c.ListModels(ctx)
+	if err != nil {
+		return "", err
+	}
+	var digest string
+	found := false
+	target := normalizeModelName(name)
+	for _, m := range models.Models {
+		if normalizeModelName(m.Name) == target {
+			digest, found = m.Digest, true
+			break
+		}
+	}
+	if !found {
+		return "", fmt.Errorf("%w: %s is not a local model", ErrModelNotFound, name)
+	}
+
+	return RequestHash(struct {
+		Digest     string              `json:"digest"`
+		Template   string              `json:"template"`
+		System     string              `json:"system"`
+		Parameters map[string][]string `json:"parameters"`
+	}{digest, info.Template, info.System, info.ParsedParameters()})
+}
+
+// CapabilityRequirements describes the model SelectModel should find
+type CapabilityRequirements struct {
+	Tools     bool
+	Vision    bool
+	Thinking  bool
+	Embedding bool
+	// Family restricts the choice to one model family
+	Family string
+	// MaxSize is the largest acceptable size in bytes, zero for any
+	MaxSize int64
+}
+
+// SelectModel returns the best local model meeting req. Among the models
+// that qualify, the one with the most parameters wins, then the smaller
+// quantized size, then the most recently modified, then the name. Servers
+// that do not report capabilities are judged from the model's template and
+// families. Only models with the completion capability qualify unless
+// req.Embedding is set, and models whose details cannot be read are
+// skipped. It fails with ErrModelNotFound when no model qualifies.
+func (c *Client) SelectModel(ctx context.Context, req CapabilityRequirements) (string, error) {
+	list, err := c.ListModels(ctx)
+	if err != nil {
+		return "", err
+	}
+
+	type candidate struct {
+		model  ModelInfo
+		params float64
+	}
+	var candidates []candidate
+	var showErrs []error
+	for _, m := range list.Models {
+		if req.MaxSize > 0 && m.Size > req.MaxSize {
+			continue
+		}
+		if req.Family != "" && m.Details.Family != req.Family && !slices.Contains(m.Details.Families, req.Family) {
+			continue
+		}
+
+		info, err := c.ShowModel(ctx, ShowModelRequest{Name: m.Name})
+		if err != nil {
+			if ctx.Err() != nil {
+				return "", err
+			}
+			// One broken model should not prevent choosing another
+			showErrs = append(showErrs, fmt.Errorf("%s: %w", m.Name, err))
+			continue
+		}
+		if !meetsRequirements(modelCapabilities(info), req) {
+			continue
+		}
+		candidates = append(candidates, candidate{model: m, params: parseParameterSize(m.Details.ParameterSize)})
+	}
+	if len(candidates) == 0 {
+		notFound := fmt.Errorf("%w: no local model meets the requirements", ErrModelNotFound)
+		return "", errors.Join(append([]error{notFound}, showErrs...)...)
+	}
+
+	best := slices.MinFunc(candidates, func(a, b candidate) int {
+		if n := cmp.Compare(b.params, a.params); n != 0 {
+			return n
+		}
+		if n := cmp.Compare(a.model.Size, b.model.Size); n != 0 {
+			return n
+		}
+		if n := b.model.ModifiedAt.Compare(a.model.ModifiedAt); n != 0 {
+			return n
+		}
+		return strings.Compare(a.model.Name, b.model.Name)
+	})
+	return best.model.Name, nil
+}
+
+// modelCapabilities returns the capabilities of a model, inferring them for
+// servers that do not report any
+func modelCapabilities(info *ShowModelResponse) []string {
+	if len(info.Capabilities) > 0 {
+		return info.Capabilities
+	}
+
+	caps := []string{"completion"}
+	if strings.Contains(info.Template, ".Tools") {
+		caps = append(caps, "tools")
+	}
+	for _, family := range info.Details.Families {
+		if family == "clip" || family == "mllama" {
+			caps = append(caps, "vision")
+			break
+		}
+	}
+	if strings.Contains(info.Details.Family, "bert") {
+		caps = []string{"embedding"}
+	}
+	return caps
+}
+
+// meetsRequirements reports whether capabilities cover req
+func meetsRequirements(caps []string, req CapabilityRequirements) bool {
+	need := map[string]bool{
+		"tools":     req.Tools,
+		"vision":    req.Vision,
+		"thinking":  req.Thinking,
+		"embedding": req.Embedding,
+		// Embedding-only models cannot chat or generate
+		"completion": !req.Embedding,
+	}
+	for capability, required := range need {
+		if required && !slices.Contains(caps, capability) {
+			return false
+		}
+	}
+	return true
+}
+
+// parseParameterSize reads a parameter count such as "7.6B" or "137M",
+// returning zero when it is missing or malformed
+func parseParameterSize(s string) float64 {
+	s = strings.TrimSpace(strings.ToUpper(s))
+	scale := 1.0
+	switch {
+	case strings.HasSuffix(s, "T"):
+		scale = 1e12
+	case strings.HasSuffix(s, "B"):
+		scale = 1e9
+	case strings.HasSuffix(s, "M"):
+		scale = 1e6
+	case strings.HasSuffix(s, "K"):
+		scale = 1e3
+	}
+	if scale != 1 {
+		s = s[:len(s)-1]
+	}
+	n, err := strconv.ParseFloat(s, 64)
+	if err != nil {
+		return 0
+	}
+	return n * scale
+}
@@ -0,0 +1,303 @@
+// models_test.go
+package ollamago
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"slices"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestSelectModel(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		if r.URL.Path == "/api/tags" {
+			fmt.Fprint(w, `{"models":[
+				{"name":"embed:latest","details":{"parameter_size":"13B"}},
+				{"name":"broken:latest","details":{"parameter_size":"70B"}},
+				{"name":"chat:latest","details":{"parameter_size":"7B"}}
+			]}`)
+			return
+		}
+		var req ShowModelRequest
+		json.NewDecoder(r.Body).Decode(&req)
+		switch req.Name {
+		case "embed:latest":
+			fmt.Fprint(w, `{"capabilities":["embedding"]}`)
+		case "chat:latest":
+			fmt.Fprint(w, `{"capabilities":["completion","tools"]}`)
+		default:
+			w.WriteHeader(http.StatusInternalServerError)
+			fmt.Fprint(w, `{"error":"unreadable manifest"}`)
+		}
+	}))
+	defer srv.Close()
+
+	tests := []struct {
+		name string
+		req  CapabilityRequirements
+		want string
+	}{
+		{"chat", CapabilityRequirements{}, "chat:latest"},
+		{"tools", CapabilityRequirements{Tools: true}, "chat:latest"},
+		{"embedding", CapabilityRequirements{Embedding: true}, "embed:latest"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := NewClient(WithBaseURL(srv.URL))
+			got, err := c.SelectModel(context.Background(), tt.req)
+			if err != nil {
+				t.Fatalf("SelectModel: %v", err)
+			}
+			if got != tt.want {
+				t.Errorf("SelectModel = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCanonicalModelName(t *testing.T) {
+	tests := []struct {
+		name string
+		want string
+	}{
+		{"llama3", "llama3:latest"},
+		{"llama3:8b", "llama3:8b"},
+		{"library/llama3", "llama3:latest"},
+		{"registry.ollama.ai/library/llama3", "llama3:latest"},
+		{"registry.ollama.ai/library/llama3:8b", "llama3:8b"},
+		{"registry.ollama.ai/user/model", "user/model:latest"},
+		{"example.com:5000/team/model", "example.com:5000/team/model:latest"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := CanonicalModelName(tt.name); got != tt.want {
+				t.Errorf("CanonicalModelName(%q) = %q, want %q", tt.name, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestGroupByModel(t *testing.T) {
+	list := ListModelsResponse{Models: []ModelInfo{
+		{Name: "registry.ollama.ai/library/llama3:latest"},
+		{Name: "mistral"},
+		{Name: "llama3"},
+		{Name: "llama3:8b"},
+		{Name: "library/mistral:latest"},
+	}}
+
+	groups := list.GroupByModel()
+	want := []struct {
+		name   string
+		models []string
+	}{
+		{"llama3:latest", []string{"registry.ollama.ai/library/llama3:latest", "llama3"}},
+		{"mistral:latest", []string{"mistral", "library/mistral:latest"}},
+		{"llama3:8b", []string{"llama3:8b"}},
+	}
+	if len(groups) != len(want) {
+		t.Fatalf("got %d groups, want %d: %+v", len(groups), len(want), groups)
+	}
+	for i, group := range groups {
+		var names []string
+		for _, m := range group.Models {
+			names = append(names, m.Name)
+		}
+		if group.Name != want[i].name || !slices.Equal(names, want[i].models) {
+			t.Errorf("group %d = %s %v, want %s %v", i, group.Name, names, want[i].name, want[i].models)
+		}
+	}
+}
+
+func TestResolveAlias(t *testing.T) {
+	srv := routeServer(t, map[string]string{"/api/tags": `{"models":[
+		{"name":"my-llama:latest","digest":"abc","modified_at":"2024-03-01T00:00:00Z"},
+		{"name":"llama3:latest","digest":"abc","modified_at":"2024-01-01T00:00:00Z"},
+		{"name":"llama-copy:latest","digest":"abc","modified_at":"2024-02-01T00:00:00Z"},
+		{"name":"b-tie:latest","digest":"def","modified_at":"2024-01-01T00:00:00Z"},
+		{"name":"a-tie:latest","digest":"def","modified_at":"2024-01-01T00:00:00Z"},
+		{"name":"mistral:latest","digest":"ghi","modified_at":"2024-01-01T00:00:00Z"}
+	]}`})
+	c := NewClient(WithBaseURL(srv.URL))
+
+	tests := []struct {
+		name, want string
+	}{
+		{"my-llama", "llama3:latest"},
+		{"llama-copy:latest", "llama3:latest"},
+		{"llama3", "llama3:latest"},
+		{"b-tie", "a-tie:latest"},
+		{"mistral", "mistral:latest"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := c.ResolveAlias(context.Background(), tt.name)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got != tt.want {
+				t.Errorf("ResolveAlias(%q) = %q, want %q", tt.name, got, tt.want)
+			}
+		})
+	}
+
+	if _, err := c.ResolveAlias(context.Background(), "missing"); err == nil {
+		t.Error("no error for a missing model")
+	}
+}
+
+func TestRecommendedOptions(t *testing.T) {
+	var sent GenerateRequest
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		switch r.URL.Path {
+		case "/api/show":
+			fmt.Fprint(w, `{"parameters":"temperature 0.6\ntop_k 20\nnum_ctx 8192\nstop \"<|im_end|>\"\nstop \"<|endoftext|>\"\nunknown_param 3"}`)
+		case "/api/generate":
+			if err := json.NewDecoder(r.Body).Decode(&sent); err != nil {
+				t.Errorf("decoding request: %v", err)
+			}
+			fmt.Fprint(w, `{"response":"ok","done":true}`)
+		}
+	}))
+	defer srv.Close()
+	c := NewClient(WithBaseURL(srv.URL))
+	ctx := context.Background()
+
+	opts, err := c.RecommendedOptions(ctx, "m")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if opts.Temperature == nil || *opts.Temperature != 0.6 ||
+		opts.TopK == nil || *opts.TopK != 20 ||
+		opts.NumCtx == nil || *opts.NumCtx != 8192 ||
+		!slices.Equal(opts.Stop, []string{"<|im_end|>", "<|endoftext|>"}) {
+		t.Fatalf("options = %+v", opts)
+	}
+
+	// The caller's override is sent alongside the other recommendations
+	opts.Temperature = ptr(0.2)
+	if _, err := c.Generate(ctx, GenerateRequest{Model: "m", Prompt: "hi", Options: opts}); err != nil {
+		t.Fatal(err)
+	}
+	if sent.Options == nil || *sent.Options.Temperature != 0.2 || *sent.Options.TopK != 20 || len(sent.Options.Stop) != 2 {
+		t.Errorf("sent options = %+v", sent.Options)
+	}
+}
+
+func TestParseParameters(t *testing.T) {
+	block := "\n" +
+		"stop                           \"<|start_header_id|>\"\n" +
+		"stop                           \"<|eot_id|>\"\n" +
+		"\n" +
+		"temperature 0.7\n" +
+		"  num_ctx\t4096  \n" +
+		"stop \"say \\\"hi\\\"\"\n" +
+		"system \"unterminated \\q\"\n"
+
+	got := parseParameters(block)
+	want := map[string][]string{
+		"stop":        {"<|start_header_id|>", "<|eot_id|>", `say "hi"`},
+		"temperature": {"0.7"},
+		"num_ctx":     {"4096"},
+		"system":      {`unterminated \q`},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("parseParameters = %q, want %q", got, want)
+	}
+}
+
+func TestUnloadAll(t *testing.T) {
+	var (
+		mu       sync.Mutex
+		unloaded = map[string]string{}
+	)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		switch r.URL.Path {
+		case "/api/ps":
+			fmt.Fprint(w, `{"models":[{"name":"a:latest","model":"a:latest"},{"name":"b:latest"},{"name":"broken:latest","model":"broken:latest"}]}`)
+		case "/api/generate":
+			var req GenerateRequest
+			if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+				t.Errorf("decoding request: %v", err)
+			}
+			mu.Lock()
+			unloaded[req.Model] = req.KeepAlive
+			mu.Unlock()
+			if req.Model == "broken:latest" {
+				w.WriteHeader(http.StatusInternalServerError)
+				fmt.Fprint(w, `{"error":"cannot unload"}`)
+				return
+			}
+			fmt.Fprint(w, `{"done":true,"done_reason":"unload"}`)
+		}
+	}))
+	defer srv.Close()
+	c := NewClient(WithBaseURL(srv.URL), WithKeepAlive(time.Hour))
+
+	err := c.UnloadAll(context.Background())
+	if err == nil || !strings.Contains(err.Error(), "unloading broken:latest") {
+		t.Errorf("err = %v, want the broken model's failure", err)
+	}
+	want := map[string]string{"a:latest": "0", "b:latest": "0", "broken:latest": "0"}
+	if !reflect.DeepEqual(unloaded, want) {
+		t.Errorf("unload requests = %v, want %v", unloaded, want)
+	}
+}
+
+func TestModelFingerprint(t *testing.T) {
+	const (
+		show = `{"template":"{{ .Prompt }}","system":"Be terse.","parameters":"temperature 0.7\nstop \"<|eot|>\""}`
+		tags = `{"models":[{"name":"m:latest","digest":"abc"}]}`
+	)
+	fingerprint := func(t *testing.T, show, tags string) (string, error) {
+		t.Helper()
+		srv := routeServer(t, map[string]string{"/api/show": show, "/api/tags": tags})
+		return NewClient(WithBaseURL(srv.URL)).ModelFingerprint(context.Background(), "m")
+	}
+
+	base, err := fingerprint(t, show, tags)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if again, _ := fingerprint(t, show, tags); again != base {
+		t.Error("fingerprint is not stable")
+	}
+
+	changes := []struct {
+		name, show, tags string
+	}{
+		{"template", strings.Replace(show, "{{ .Prompt }}", "<u>{{ .Prompt }}</u>", 1), tags},
+		{"system", strings.Replace(show, "Be terse.", "Be kind.", 1), tags},
+		{"parameters", strings.Replace(show, "temperature 0.7", "temperature 0.8", 1), tags},
+		{"digest", show, strings.Replace(tags, "abc", "def", 1)},
+	}
+	for _, tt := range changes {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := fingerprint(t, tt.show, tt.tags)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got == base {
+				t.Errorf("fingerprint unchanged after changing the %s", tt.name)
+			}
+		})
+	}
+
+	t.Run("not local", func(t *testing.T) {
+		_, err := fingerprint(t, show, `{"models":[{"name":"other:latest","digest":"abc"}]}`)
+		if !errors.Is(err, ErrModelNotFound) {
+			t.Errorf("err = %v, want ErrModelNotFound", err)
+		}
+	})
+}
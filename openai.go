@@ -0,0 +1,181 @@
+// openai.go
+package ollamago
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+)
+
+// OpenAIChatMessage represents a chat message in the OpenAI chat format
+type OpenAIChatMessage struct {
+	Role       string           `json:"role"`
+	Content    string           `json:"content"`
+	Name       string           `json:"name,omitempty"`
+	ToolCalls  []OpenAIToolCall `json:"tool_calls,omitempty"`
+	ToolCallID string           `json:"tool_call_id,omitempty"`
+}
+
+// OpenAIToolCall represents a tool call in the OpenAI chat format
+type OpenAIToolCall struct {
+	ID       string             `json:"id"`
+	Type     string             `json:"type"`
+	Function OpenAIFunctionCall `json:"function"`
+}
+
+// OpenAIFunctionCall represents the details of a tool call in the OpenAI chat
+// format, where arguments are a JSON encoded string
+type OpenAIFunctionCall struct {
+	Name      string `json:"name"`
+	Arguments string `json:"arguments"`
+}
+
+// OpenAIChatRequest is a request to the OpenAI-compatible chat completions
+// endpoint
+type OpenAIChatRequest struct {
+	Model            string                `json:"model"`
+	Messages         []OpenAIChatMessage   `json:"messages"`
+	Temperature      *float64              `json:"temperature,omitempty"`
+	TopP             *float64              `json:"top_p,omitempty"`
+	MaxTokens        *int                  `json:"max_tokens,omitempty"`
+	Stop             []string              `json:"stop,omitempty"`
+	Seed             *int                  `json:"seed,omitempty"`
+	PresencePenalty  *float64              `json:"presence_penalty,omitempty"`
+	FrequencyPenalty *float64              `json:"frequency_penalty,omitempty"`
+	ResponseFormat   *OpenAIResponseFormat `json:"response_format,omitempty"`
+	Tools            []Tool                `json:"tools,omitempty"`
+	Stream           bool                  `json:"stream"`
+}
+
+// OpenAIResponseFormat selects JSON mode in the OpenAI chat format
+type OpenAIResponseFormat struct {
+	Type string `json:"type"`
+}
+
+// OpenAIChatResponse is a response from the OpenAI-compatible chat
+// completions endpoint
+type OpenAIChatResponse struct {
+	ID                string         `json:"id"`
+	Object            string         `json:"object"`
+	Created           int64          `json:"created"`
+	Model             string         `json:"model"`
+	SystemFingerprint string         `json:"system_fingerprint"`
+	Choices           []OpenAIChoice `json:"choices"`
+	Usage             OpenAIUsage    `json:"usage"`
+}
+
+// OpenAIChoice is one completion in an OpenAIChatResponse. FinishReason is
+// "stop", "length" or "tool_calls".
+type OpenAIChoice struct {
+	Index        int               `json:"index"`
+	Message      OpenAIChatMessage `json:"message"`
+	FinishReason string            `json:"finish_reason"`
+}
+
+// OpenAIUsage reports the tokens used by an OpenAI-compatible request
+type OpenAIUsage struct {
+	PromptTokens     int `json:"prompt_tokens"`
+	CompletionTokens int `json:"completion_tokens"`
+	TotalTokens      int `json:"total_tokens"`
+}
+
+// ChatCompletion sends a chat request to the OpenAI-compatible
+// /v1/chat/completions endpoint, for code written against the OpenAI
+// request and response shapes. Streaming is not supported.
+func (c *Client) ChatCompletion(ctx context.Context, req OpenAIChatRequest) (*OpenAIChatResponse, error) {
+	if req.Model == "" {
+		return nil, &RequestError{Message: "model is required"}
+	}
+	if len(req.Messages) == 0 {
+		return nil, &RequestError{Message: "at least one message is required"}
+	}
+	req.Stream = false
+
+	var resp OpenAIChatResponse
+	if err := c.request(ctx, http.MethodPost, "/v1/chat/completions", req, &resp, false); err != nil {
+		return nil, err
+	}
+	return &resp, nil
+}
+
+// ToOpenAIMessages converts a conversation to the OpenAI chat format.
+// Tool calls without an ID are given a generated one, and tool result
+// messages are linked to the preceding assistant tool call with the same
+// function name. Images are not carried over.
+func ToOpenAIMessages(messages []Message) []OpenAIChatMessage {
+	out := make([]OpenAIChatMessage, 0, len(messages))
+	// pending holds the IDs of tool calls that have not been answered yet,
+	// keyed by function name
+	pending := make(map[string][]string)
+	var order []string
+	callCount := 0
+
+	for _, msg := range messages {
+		oaMsg := OpenAIChatMessage{
+			Role:    msg.Role,
+			Content: msg.Content,
+			Name:    msg.Name,
+		}
+
+		switch msg.Role {
+		case "assistant":
+			for _, tc := range msg.ToolCalls {
+				callCount++
+				id := tc.ID
+				if id == "" {
+					id = fmt.Sprintf("call_%d", callCount)
+				}
+				typ := tc.Type
+				if typ == "" {
+					typ = "function"
+				}
+				args := string(tc.Function.Arguments)
+				if args == "" {
+					args = "{}"
+				}
+				oaMsg.ToolCalls = append(oaMsg.ToolCalls, OpenAIToolCall{
+					ID:   id,
+					Type: typ,
+					Function: OpenAIFunctionCall{
+						Name:      tc.Function.Name,
+						Arguments: args,
+					},
+				})
+				pending[tc.Function.Name] = append(pending[tc.Function.Name], id)
+				order = append(order, id)
+			}
+		case "tool":
+			id := ""
+			if ids := pending[msg.Name]; len(ids) > 0 {
+				id = ids[0]
+				pending[msg.Name] = ids[1:]
+			} else if len(order) > 0 {
+				// Fall back to the oldest unanswered call
+				id = order[0]
+				for name, ids := range pending {
+					if len(ids) > 0 && ids[0] == id {
+						pending[name] = ids[1:]
+						break
+					}
+				}
+			}
+			order = removeString(order, id)
+			oaMsg.ToolCallID = id
+			oaMsg.Name = ""
+		}
+
+		out = append(out, oaMsg)
+	}
+
+	return out
+}
+
+// removeString returns s without the first occurrence of v
+func removeString(s []string, v string) []string {
+	for i := range s {
+		if s[i] == v {
+			return append(s[:i:i], s[i+1:]...)
+		}
+	}
+	return s
+}
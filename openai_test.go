@@ -0,0 +1,42 @@
+// openai_test.go
+package ollamago
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestToOpenAIMessages(t *testing.T) {
+	messages := []Message{
+		{Role: "user", Content: "Weather and time in Paris?", Images: []Image{ImageFromBytes([]byte("png"))}},
+		{Role: "assistant", ToolCalls: []ToolCall{
+			{Function: FunctionCall{Name: "weather", Arguments: []byte(`{"city":"Paris"}`)}},
+			{ID: "given", Function: FunctionCall{Name: "time"}},
+			{Function: FunctionCall{Name: "weather", Arguments: []byte(`{"city":"Lyon"}`)}},
+		}},
+		// Matched by name, in call order
+		{Role: "tool", Name: "time", Content: "noon"},
+		{Role: "tool", Name: "weather", Content: "sunny"},
+		// No name: falls back to the oldest unanswered call
+		{Role: "tool", Content: "rainy"},
+		{Role: "assistant", Content: "Sunny in Paris, rainy in Lyon, noon."},
+	}
+
+	want := []OpenAIChatMessage{
+		{Role: "user", Content: "Weather and time in Paris?"},
+		{Role: "assistant", ToolCalls: []OpenAIToolCall{
+			{ID: "call_1", Type: "function", Function: OpenAIFunctionCall{Name: "weather", Arguments: `{"city":"Paris"}`}},
+			{ID: "given", Type: "function", Function: OpenAIFunctionCall{Name: "time", Arguments: "{}"}},
+			{ID: "call_3", Type: "function", Function: OpenAIFunctionCall{Name: "weather", Arguments: `{"city":"Lyon"}`}},
+		}},
+		{Role: "tool", Content: "noon", ToolCallID: "given"},
+		{Role: "tool", Content: "sunny", ToolCallID: "call_1"},
+		{Role: "tool", Content: "rainy", ToolCallID: "call_3"},
+		{Role: "assistant", Content: "Sunny in Paris, rainy in Lyon, noon."},
+	}
+
+	got := ToOpenAIMessages(messages)
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("ToOpenAIMessages =\n%+v\nwant\n%+v", got, want)
+	}
+}
@@ -0,0 +1,234 @@
+// options.go
+package ollamago
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"reflect"
+	"strings"
+)
+
+// DefaultMaxStopSequences is the default limit on the number of stop
+// sequences accepted in Options.Stop
+const DefaultMaxStopSequences = 16
+
+// WithMaxStopSequences sets the maximum number of stop sequences allowed in
+// Options.Stop. A limit of zero or less disables the check.
+func WithMaxStopSequences(limit int) Option {
+	return func(c *Client) {
+		c.maxStop = limit
+	}
+}
+
+// WithOptionsValidation controls whether requests check their Options with
+// Validate before sending. It is on by default; turn it off to pass values
+// outside the documented ranges to experimental models.
+func WithOptionsValidation(enabled bool) Option {
+	return func(c *Client) {
+		c.validateOptions = enabled
+	}
+}
+
+// Validate checks that option values are within their valid ranges and
+// returns a RequestError listing every field that is not
+func (o *Options) Validate() error {
+	if o == nil {
+		return nil
+	}
+
+	var problems []string
+	check := func(bad bool, format string, args ...interface{}) {
+		if bad {
+			problems = append(problems, fmt.Sprintf(format, args...))
+		}
+	}
+	if o.Temperature != nil {
+		check(*o.Temperature < 0, "temperature %v is negative", *o.Temperature)
+	}
+	if o.TopP != nil {
+		check(*o.TopP < 0 || *o.TopP > 1, "top_p %v is outside [0, 1]", *o.TopP)
+	}
+	if o.TypicalP != nil {
+		check(*o.TypicalP < 0 || *o.TypicalP > 1, "typical_p %v is outside [0, 1]", *o.TypicalP)
+	}
+	if o.TopK != nil {
+		check(*o.TopK <= 0, "top_k %d is not positive", *o.TopK)
+	}
+	if o.TFSZ != nil {
+		check(*o.TFSZ < 0, "tfs_z %v is negative", *o.TFSZ)
+	}
+	if o.RepeatPenalty != nil {
+		check(*o.RepeatPenalty < 0, "repeat_penalty %v is negative", *o.RepeatPenalty)
+	}
+	if o.RepeatLastN != nil {
+		check(*o.RepeatLastN < -1, "repeat_last_n %d is less than -1", *o.RepeatLastN)
+	}
+	if o.Mirostat != nil {
+		check(*o.Mirostat < 0 || *o.Mirostat > 2, "mirostat %d is not 0, 1 or 2", *o.Mirostat)
+	}
+	if o.MirostatTau != nil {
+		check(*o.MirostatTau < 0, "mirostat_tau %v is negative", *o.MirostatTau)
+	}
+	if o.MirostatEta != nil {
+		check(*o.MirostatEta < 0, "mirostat_eta %v is negative", *o.MirostatEta)
+	}
+	if o.NumCtx != nil {
+		check(*o.NumCtx <= 0, "num_ctx %d is not positive", *o.NumCtx)
+	}
+	if o.NumPredict != nil {
+		check(*o.NumPredict < -2, "num_predict %d is less than -2", *o.NumPredict)
+	}
+	if o.NumThread != nil {
+		check(*o.NumThread < 0, "num_thread %d is negative", *o.NumThread)
+	}
+
+	if len(problems) > 0 {
+		return &RequestError{Message: "invalid options: " + strings.Join(problems, "; ")}
+	}
+	return nil
+}
+
+// clone returns a deep copy of the options, so the copy can be modified
+// while other goroutines keep using the original
+func (o *Options) clone() *Options {
+	if o == nil {
+		return nil
+	}
+
+	c := *o
+	v := reflect.ValueOf(&c).Elem()
+	for i := 0; i < v.NumField(); i++ {
+		f := v.Field(i)
+		if f.Kind() == reflect.Ptr && !f.IsNil() {
+			p := reflect.New(f.Type().Elem())
+			p.Elem().Set(f.Elem())
+			f.Set(p)
+		}
+	}
+	if o.Stop != nil {
+		c.Stop = append([]string(nil), o.Stop...)
+	}
+	return &c
+}
+
+// prepareOptions validates and canonicalizes request options. The caller's
+// Options may be shared between goroutines, so they are never modified:
+// every change is made on a clone.
+func (c *Client) prepareOptions(o *Options) (*Options, error) {
+	if o == nil {
+		return nil, nil
+	}
+
+	if c.validateOptions {
+		if err := o.Validate(); err != nil {
+			return nil, err
+		}
+	}
+
+	opts := o.clone()
+	if len(opts.Stop) > 0 {
+		stop, err := canonicalStop(opts.Stop, c.maxStop)
+		if err != nil {
+			return nil, err
+		}
+		opts.Stop = stop
+	}
+	return opts, nil
+}
+
+// canonicalStop removes duplicate stop sequences and checks the result
+// against limit. Whitespace is significant in stop sequences, so entries are
+// not trimmed, but empty entries are rejected.
+func canonicalStop(stop []string, limit int) ([]string, error) {
+	seen := make(map[string]bool, len(stop))
+	out := make([]string, 0, len(stop))
+	for i, s := range stop {
+		if s == "" {
+			return nil, &RequestError{Message: fmt.Sprintf("stop sequence %d is empty", i)}
+		}
+		if seen[s] {
+			continue
+		}
+		seen[s] = true
+		out = append(out, s)
+	}
+
+	if limit > 0 && len(out) > limit {
+		return nil, &RequestError{Message: fmt.Sprintf("too many stop sequences: %d (limit %d)", len(out), limit)}
+	}
+	return out, nil
+}
+
+// ptr returns a pointer to v
+func ptr[T any](v T) *T {
+	return &v
+}
+
+// PresetCreative returns options tuned for varied, imaginative output such
+// as stories and brainstorming. Each call returns a new value that can be
+// adjusted freely.
+func PresetCreative() *Options {
+	return &Options{
+		Temperature:   ptr(1.0),
+		TopP:          ptr(0.95),
+		TopK:          ptr(80),
+		RepeatPenalty: ptr(1.15),
+	}
+}
+
+// PresetBalanced returns options for general purpose chat, trading some
+// variety for coherence. Each call returns a new value that can be adjusted
+// freely.
+func PresetBalanced() *Options {
+	return &Options{
+		Temperature:   ptr(0.7),
+		TopP:          ptr(0.9),
+		TopK:          ptr(40),
+		RepeatPenalty: ptr(1.1),
+	}
+}
+
+// PresetPrecise returns options for focused, mostly deterministic output
+// such as extraction, classification and code. Each call returns a new value
+// that can be adjusted freely.
+func PresetPrecise() *Options {
+	return &Options{
+		Temperature:   ptr(0.1),
+		TopP:          ptr(0.5),
+		TopK:          ptr(10),
+		RepeatPenalty: ptr(1.05),
+	}
+}
+
+// LoadOptionsFromFile reads Options from a JSON file using the API's
+// parameter names, such as {"temperature": 0.7, "num_ctx": 8192}, so
+// generation settings can be tuned without rebuilding. Unknown parameters
+// and invalid stop sequences or values are rejected.
+func LoadOptionsFromFile(path string) (*Options, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("opening options file: %w", err)
+	}
+	defer f.Close()
+
+	var opts Options
+	dec := json.NewDecoder(f)
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(&opts); err != nil {
+		return nil, fmt.Errorf("parsing options file %s: %w", path, err)
+	}
+	if dec.More() {
+		return nil, fmt.Errorf("parsing options file %s: unexpected data after options", path)
+	}
+
+	if len(opts.Stop) > 0 {
+		if opts.Stop, err = canonicalStop(opts.Stop, DefaultMaxStopSequences); err != nil {
+			return nil, fmt.Errorf("options file %s: %w", path, err)
+		}
+	}
+	if err := opts.Validate(); err != nil {
+		return nil, fmt.Errorf("options file %s: %w", path, err)
+	}
+	return &opts, nil
+}
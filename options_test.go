@@ -0,0 +1,184 @@
+// options_test.go
+package ollamago
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"reflect"
+	"slices"
+	"strings"
+	"sync"
+	"testing"
+)
+
+func TestSharedOptionsConcurrentRequests(t *testing.T) {
+	var (
+		mu    sync.Mutex
+		stops [][]string
+	)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req struct {
+			Options Options `json:"options"`
+		}
+		json.NewDecoder(r.Body).Decode(&req)
+		mu.Lock()
+		stops = append(stops, req.Options.Stop)
+		mu.Unlock()
+		w.Header().Set("Content-Type", "application/json")
+		fmt.Fprint(w, `{"response":"ok","message":{"role":"assistant","content":"ok"},"done":true}`)
+	}))
+	defer srv.Close()
+
+	opts := &Options{
+		Temperature: ptr(0.5),
+		Seed:        ptr(7),
+		Stop:        []string{"\n", "</s>", "\n"},
+	}
+	want := opts.clone()
+
+	c := NewClient(WithBaseURL(srv.URL))
+	var wg sync.WaitGroup
+	for i := 0; i < 16; i++ {
+		wg.Add(2)
+		go func() {
+			defer wg.Done()
+			if _, err := c.Generate(context.Background(), GenerateRequest{Model: "m", Prompt: "hi", Options: opts}); err != nil {
+				t.Error(err)
+			}
+		}()
+		go func() {
+			defer wg.Done()
+			if _, err := c.Chat(context.Background(), ChatRequest{Model: "m", Messages: []Message{{Role: "user", Content: "hi"}}, Options: opts}); err != nil {
+				t.Error(err)
+			}
+		}()
+	}
+	wg.Wait()
+
+	if !reflect.DeepEqual(opts, want) {
+		t.Errorf("shared options changed: %+v, want %+v", opts, want)
+	}
+	for _, stop := range stops {
+		if !slices.Equal(stop, []string{"\n", "</s>"}) {
+			t.Errorf("sent stop = %q, want duplicates removed", stop)
+		}
+	}
+}
+
+func TestCanonicalStop(t *testing.T) {
+	tests := []struct {
+		name    string
+		stop    []string
+		limit   int
+		want    []string
+		wantErr bool
+	}{
+		{"duplicates removed", []string{"\n", "END", "\n"}, 16, []string{"\n", "END"}, false},
+		{"whitespace kept", []string{" END", "END"}, 16, []string{" END", "END"}, false},
+		{"duplicates do not count", []string{"a", "a", "b"}, 2, []string{"a", "b"}, false},
+		{"too many", []string{"a", "b", "c"}, 2, nil, true},
+		{"no limit", []string{"a", "b", "c"}, 0, []string{"a", "b", "c"}, false},
+		{"empty entry", []string{"a", ""}, 16, nil, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := canonicalStop(tt.stop, tt.limit)
+			if tt.wantErr {
+				if !errors.Is(err, ErrInvalidRequest) {
+					t.Errorf("err = %v, want ErrInvalidRequest", err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !slices.Equal(got, tt.want) {
+				t.Errorf("canonicalStop = %q, want %q", got, tt.want)
+			}
+		})
+	}
+
+	// The client limit applies to requests
+	c := NewClient(WithBaseURL("http://127.0.0.1:1"), WithMaxStopSequences(1))
+	_, err := c.Generate(context.Background(), GenerateRequest{Model: "m", Options: &Options{Stop: []string{"a", "b"}}})
+	if !errors.Is(err, ErrInvalidRequest) {
+		t.Errorf("err = %v, want ErrInvalidRequest for too many stops", err)
+	}
+}
+
+func TestPresets(t *testing.T) {
+	tests := []struct {
+		name              string
+		preset            func() *Options
+		temperature, topP float64
+		topK              int
+	}{
+		{"creative", PresetCreative, 1.0, 0.95, 80},
+		{"balanced", PresetBalanced, 0.7, 0.9, 40},
+		{"precise", PresetPrecise, 0.1, 0.5, 10},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			opts := tt.preset()
+			if err := opts.Validate(); err != nil {
+				t.Errorf("Validate: %v", err)
+			}
+			if *opts.Temperature != tt.temperature || *opts.TopP != tt.topP || *opts.TopK != tt.topK {
+				t.Errorf("preset = temperature %v, top_p %v, top_k %v", *opts.Temperature, *opts.TopP, *opts.TopK)
+			}
+
+			// Each call returns a fresh value
+			*opts.Temperature = 2
+			if *tt.preset().Temperature != tt.temperature {
+				t.Error("changing a preset changed later calls")
+			}
+		})
+	}
+}
+
+func TestLoadOptionsFromFile(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		wantErr string
+	}{
+		{"valid", `{"temperature": 0.7, "num_ctx": 8192, "stop": ["\n", "\n", "END"]}`, ""},
+		{"unknown field", `{"temperature": 0.7, "temprature": 0.2}`, "unknown field"},
+		{"out of range", `{"top_p": 1.5}`, "top_p"},
+		{"empty stop", `{"stop": [""]}`, "stop sequence 0 is empty"},
+		{"malformed", `{"temperature": 0.7,`, "parsing options file"},
+		{"trailing data", `{"temperature": 0.7} {}`, "unexpected data"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "options.json")
+			if err := os.WriteFile(path, []byte(tt.content), 0o600); err != nil {
+				t.Fatal(err)
+			}
+
+			opts, err := LoadOptionsFromFile(path)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Errorf("err = %v, want it to mention %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if *opts.Temperature != 0.7 || *opts.NumCtx != 8192 || !slices.Equal(opts.Stop, []string{"\n", "END"}) {
+				t.Errorf("options = %+v", opts)
+			}
+		})
+	}
+
+	if _, err := LoadOptionsFromFile(filepath.Join(t.TempDir(), "missing.json")); !errors.Is(err, os.ErrNotExist) {
+		t.Errorf("err = %v, want os.ErrNotExist", err)
+	}
+}
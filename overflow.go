@@ -0,0 +1,175 @@
+// overflow.go
+package ollamago
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strings"
+)
+
+// TrimStrategy shortens a conversation to fit maxTokens. It must not modify
+// msgs.
+type TrimStrategy func(msgs []Message, maxTokens int) []Message
+
+// maxTrimAttempts bounds how many times a request is retried after
+// trimming, halving the budget each time
+const maxTrimAttempts = 3
+
+// WithAutoTrimOnOverflow retries Generate and Chat requests that fail with
+// ErrContextLengthExceeded after shortening them to fit the model's context
+// length. Chats lose their oldest messages, by WithTrimStrategy. Generate
+// requests first lose their Context, forgetting the earlier conversation,
+// and then the beginning of their prompt. Set WithReturnPrompt or
+// WithEchoRequest to see what was actually sent.
+func WithAutoTrimOnOverflow(enabled bool) Option {
+	return func(c *Client) {
+		c.autoTrim = enabled
+	}
+}
+
+// WithTrimStrategy sets how conversations are shortened on overflow. The
+// default drops the oldest non-system messages using TrimMessagesWith and
+// the client's TokenCounter.
+func WithTrimStrategy(strategy TrimStrategy) Option {
+	return func(c *Client) {
+		c.trimStrategy = strategy
+	}
+}
+
+// trim shortens msgs with the configured strategy
+func (c *Client) trim(msgs []Message, maxTokens int) []Message {
+	if c.trimStrategy != nil {
+		return c.trimStrategy(msgs, maxTokens)
+	}
+	return TrimMessagesWith(c.TokenCounter(), msgs, maxTokens, true)
+}
+
+// contextLength returns the number of tokens a request can use: NumCtx when
+// set, or the context length the model reports
+func (c *Client) contextLength(ctx context.Context, model string, opts *Options) (int, error) {
+	if opts != nil && opts.NumCtx != nil {
+		return *opts.NumCtx, nil
+	}
+
+	info, err := c.ShowModel(ctx, ShowModelRequest{Name: model})
+	if err != nil {
+		return 0, err
+	}
+	for key, value := range info.ModelInfo {
+		if strings.HasSuffix(key, ".context_length") {
+			if n := modelInfoInt(value); n > 0 {
+				return n, nil
+			}
+		}
+	}
+	return 0, errors.New("model does not report its context length")
+}
+
+// modelInfoInt returns a numeric model info value as an int, whether it was
+// decoded as a float64 or, with UseNumber, as a json.Number
+func modelInfoInt(value interface{}) int {
+	switch v := value.(type) {
+	case float64:
+		return int(v)
+	case json.Number:
+		n, err := v.Int64()
+		if err != nil {
+			f, _ := v.Float64()
+			return int(f)
+		}
+		return int(n)
+	}
+	return 0
+}
+
+// trimBudget returns the prompt budget for a request, leaving room for the
+// completion
+func (c *Client) trimBudget(ctx context.Context, model string, opts *Options) (int, error) {
+	n, err := c.contextLength(ctx, model, opts)
+	if err != nil {
+		return 0, err
+	}
+	reserve := n / 4
+	if opts != nil && opts.NumPredict != nil && *opts.NumPredict > 0 && *opts.NumPredict < n {
+		reserve = *opts.NumPredict
+	}
+	return n - reserve, nil
+}
+
+// retryTrimmedChat retries a chat that overflowed the context with fewer
+// messages. req is updated to the request that was last sent. When nothing
+// can be trimmed the original error is returned.
+func (c *Client) retryTrimmedChat(ctx context.Context, req *ChatRequest, resp *ChatResponse, overflow error) error {
+	budget, err := c.trimBudget(ctx, req.Model, req.Options)
+	if err != nil {
+		return overflow
+	}
+
+	for attempt := 0; attempt < maxTrimAttempts; attempt++ {
+		trimmed := c.trim(req.Messages, budget)
+		budget /= 2
+		if len(trimmed) == 0 || len(trimmed) >= len(req.Messages) {
+			continue
+		}
+		req.Messages = trimmed
+
+		err := c.request(ctx, http.MethodPost, "/api/chat", req, resp, false)
+		if !errors.Is(err, ErrContextLengthExceeded) {
+			return err
+		}
+		overflow = err
+	}
+	return overflow
+}
+
+// retryTrimmedGenerate retries a generation that overflowed the context,
+// first without the previous context and then with the start of the prompt
+// cut off. req is updated to the request that was last sent.
+func (c *Client) retryTrimmedGenerate(ctx context.Context, req *GenerateRequest, resp *GenerateResponse, overflow error) error {
+	budget, err := c.trimBudget(ctx, req.Model, req.Options)
+	if err != nil {
+		return overflow
+	}
+
+	counter := c.TokenCounter()
+	for attempt := 0; attempt < maxTrimAttempts; attempt++ {
+		switch {
+		case len(req.Context) > 0:
+			req.Context = nil
+		case counter.Count(req.Prompt) > budget:
+			req.Prompt = truncatePrompt(counter, req.Prompt, budget)
+			budget /= 2
+		default:
+			budget /= 2
+			continue
+		}
+
+		err := c.request(ctx, http.MethodPost, "/api/generate", req, resp, false)
+		if !errors.Is(err, ErrContextLengthExceeded) {
+			return err
+		}
+		overflow = err
+	}
+	return overflow
+}
+
+// truncatePrompt keeps the end of prompt, where the instruction usually is,
+// cutting whole runes from the start until it fits maxTokens
+func truncatePrompt(counter TokenCounter, prompt string, maxTokens int) string {
+	runes := []rune(prompt)
+	for len(runes) > 0 {
+		n := counter.Count(string(runes))
+		if n <= maxTokens {
+			break
+		}
+		// Cut in proportion to the excess, and always by at least one rune
+		cut := len(runes) * (n - maxTokens) / n
+		if cut < 1 {
+			cut = 1
+		}
+		runes = runes[cut:]
+	}
+	return string(runes)
+}
@@ -0,0 +1,160 @@
+// overflow_test.go
+package ollamago
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestContextLengthWithUseNumber(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		fmt.Fprint(w, `{"model_info":{"llama.context_length":8192}}`)
+	}))
+	defer srv.Close()
+
+	tests := []struct {
+		name string
+		opts []Option
+	}{
+		{"default", nil},
+		{"use number", []Option{WithDecoderOptions((*json.Decoder).UseNumber)}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := NewClient(append([]Option{WithBaseURL(srv.URL)}, tt.opts...)...)
+			n, err := c.contextLength(context.Background(), "m", nil)
+			if err != nil {
+				t.Fatalf("contextLength: %v", err)
+			}
+			if n != 8192 {
+				t.Errorf("context length = %d, want 8192", n)
+			}
+		})
+	}
+}
+
+// overflowServer fails the first generate or chat request with a context
+// length error and records the bodies of the ones after it
+func overflowServer(t *testing.T, bodies *[]json.RawMessage) *httptest.Server {
+	t.Helper()
+	failed := false
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		if r.URL.Path == "/api/show" {
+			fmt.Fprint(w, `{"model_info":{"llama.context_length":100}}`)
+			return
+		}
+		if !failed {
+			failed = true
+			w.WriteHeader(http.StatusBadRequest)
+			fmt.Fprint(w, `{"error":"input length exceeds the context length"}`)
+			return
+		}
+		var body json.RawMessage
+		json.NewDecoder(r.Body).Decode(&body)
+		*bodies = append(*bodies, body)
+		fmt.Fprint(w, `{"response":"ok","message":{"role":"assistant","content":"ok"},"done":true}`)
+	}))
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+func TestAutoTrimChat(t *testing.T) {
+	var bodies []json.RawMessage
+	srv := overflowServer(t, &bodies)
+	c := NewClient(WithBaseURL(srv.URL), WithAutoTrimOnOverflow(true), WithEchoRequest(true))
+
+	long := strings.Repeat("word ", 40)
+	resp, err := c.Chat(context.Background(), ChatRequest{
+		Model: "m",
+		Messages: []Message{
+			{Role: "system", Content: "be brief"},
+			{Role: "user", Content: long},
+			{Role: "assistant", Content: long},
+			{Role: "user", Content: "last question"},
+		},
+	})
+	if err != nil {
+		t.Fatalf("Chat: %v", err)
+	}
+	if len(bodies) != 1 {
+		t.Fatalf("retries = %d, want 1", len(bodies))
+	}
+	var roles []string
+	for _, msg := range resp.Request.Messages {
+		roles = append(roles, msg.Role)
+	}
+	// The oldest user message is dropped, the system message kept
+	if want := []string{"system", "assistant", "user"}; !slices.Equal(roles, want) {
+		t.Errorf("retried messages = %q, want %q", roles, want)
+	}
+}
+
+func TestAutoTrimGenerate(t *testing.T) {
+	long := strings.Repeat("word ", 200) + "the question"
+
+	tests := []struct {
+		name       string
+		req        GenerateRequest
+		wantPrompt func(string) bool
+	}{
+		{
+			name:       "drops context",
+			req:        GenerateRequest{Model: "m", Prompt: "short", Context: []int{1, 2, 3}},
+			wantPrompt: func(p string) bool { return p == "short" },
+		},
+		{
+			name: "cuts prompt",
+			req:  GenerateRequest{Model: "m", Prompt: long},
+			wantPrompt: func(p string) bool {
+				return len(p) < len(long) && strings.HasSuffix(p, "the question")
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var bodies []json.RawMessage
+			srv := overflowServer(t, &bodies)
+			c := NewClient(WithBaseURL(srv.URL), WithAutoTrimOnOverflow(true))
+
+			if _, err := c.Generate(context.Background(), tt.req); err != nil {
+				t.Fatalf("Generate: %v", err)
+			}
+			if len(bodies) != 1 {
+				t.Fatalf("retries = %d, want 1", len(bodies))
+			}
+			var sent GenerateRequest
+			if err := json.Unmarshal(bodies[0], &sent); err != nil {
+				t.Fatal(err)
+			}
+			if len(sent.Context) != 0 {
+				t.Errorf("retry kept context %v", sent.Context)
+			}
+			if !tt.wantPrompt(sent.Prompt) {
+				t.Errorf("retry prompt = %q", sent.Prompt)
+			}
+		})
+	}
+}
+
+func TestAutoTrimOff(t *testing.T) {
+	var bodies []json.RawMessage
+	srv := overflowServer(t, &bodies)
+	c := NewClient(WithBaseURL(srv.URL))
+
+	_, err := c.Generate(context.Background(), GenerateRequest{Model: "m", Prompt: "hi"})
+	if !errors.Is(err, ErrContextLengthExceeded) {
+		t.Errorf("err = %v, want ErrContextLengthExceeded", err)
+	}
+	if len(bodies) != 0 {
+		t.Errorf("request was retried without auto trim")
+	}
+}
@@ -0,0 +1,147 @@
+// priority.go
+package ollamago
+
+import (
+	"container/heap"
+	"context"
+	"io"
+	"sync"
+)
+
+// Priority orders requests waiting in a client's priority queue
+type Priority int
+
+const (
+	// PriorityLow is meant for batch work such as bulk embeddings
+	PriorityLow Priority = iota
+	// PriorityNormal is used for requests without an explicit priority
+	PriorityNormal
+	// PriorityHigh is meant for interactive requests
+	PriorityHigh
+)
+
+type priorityKey struct{}
+
+// ContextWithPriority returns a context that dispatches requests with the
+// given priority when the client uses WithPriorityQueue
+func ContextWithPriority(ctx context.Context, p Priority) context.Context {
+	return context.WithValue(ctx, priorityKey{}, p)
+}
+
+// priorityFromContext returns the priority stored in ctx, or PriorityNormal
+func priorityFromContext(ctx context.Context) Priority {
+	if p, ok := ctx.Value(priorityKey{}).(Priority); ok {
+		return p
+	}
+	return PriorityNormal
+}
+
+// priorityQueue is a semaphore that grants free slots to the highest
+// priority waiter, in arrival order within a priority
+type priorityQueue struct {
+	mu       sync.Mutex
+	limit    int
+	inFlight int
+	seq      uint64
+	waiters  waiterHeap
+}
+
+type waiter struct {
+	priority Priority
+	seq      uint64
+	ready    chan struct{}
+	index    int
+}
+
+func newPriorityQueue(limit int) *priorityQueue {
+	return &priorityQueue{limit: limit}
+}
+
+// acquire blocks until a slot is available or ctx is done
+func (q *priorityQueue) acquire(ctx context.Context, p Priority) error {
+	q.mu.Lock()
+	if q.inFlight < q.limit && len(q.waiters) == 0 {
+		q.inFlight++
+		q.mu.Unlock()
+		return nil
+	}
+	q.seq++
+	w := &waiter{priority: p, seq: q.seq, ready: make(chan struct{})}
+	heap.Push(&q.waiters, w)
+	q.mu.Unlock()
+
+	select {
+	case <-w.ready:
+		return nil
+	case <-ctx.Done():
+		q.mu.Lock()
+		if w.index >= 0 {
+			heap.Remove(&q.waiters, w.index)
+			q.mu.Unlock()
+			return ctx.Err()
+		}
+		q.mu.Unlock()
+		// The slot was granted while giving up, hand it on
+		q.release()
+		return ctx.Err()
+	}
+}
+
+// release frees a slot, handing it directly to the next waiter if any
+func (q *priorityQueue) release() {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if len(q.waiters) > 0 {
+		w := heap.Pop(&q.waiters).(*waiter)
+		close(w.ready)
+		return
+	}
+	q.inFlight--
+}
+
+// waiterHeap implements heap.Interface over queued requests
+type waiterHeap []*waiter
+
+func (h waiterHeap) Len() int { return len(h) }
+
+func (h waiterHeap) Less(i, j int) bool {
+	if h[i].priority != h[j].priority {
+		return h[i].priority > h[j].priority
+	}
+	return h[i].seq < h[j].seq
+}
+
+func (h waiterHeap) Swap(i, j int) {
+	h[i], h[j] = h[j], h[i]
+	h[i].index = i
+	h[j].index = j
+}
+
+func (h *waiterHeap) Push(x interface{}) {
+	w := x.(*waiter)
+	w.index = len(*h)
+	*h = append(*h, w)
+}
+
+func (h *waiterHeap) Pop() interface{} {
+	old := *h
+	n := len(old)
+	w := old[n-1]
+	old[n-1] = nil
+	w.index = -1
+	*h = old[:n-1]
+	return w
+}
+
+// releaseOnClose calls release once when the body is closed
+type releaseOnClose struct {
+	io.ReadCloser
+	release func()
+	once    sync.Once
+}
+
+func (r *releaseOnClose) Close() error {
+	err := r.ReadCloser.Close()
+	r.once.Do(r.release)
+	return err
+}
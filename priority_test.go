@@ -0,0 +1,64 @@
+// priority_test.go
+package ollamago
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestPriorityQueue(t *testing.T) {
+	var (
+		mu    sync.Mutex
+		order []string
+	)
+	release := make(chan struct{})
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req GenerateRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			t.Errorf("decoding request: %v", err)
+		}
+		mu.Lock()
+		order = append(order, req.Prompt)
+		mu.Unlock()
+		if req.Prompt == "first" {
+			<-release
+		}
+		w.Header().Set("Content-Type", "application/json")
+		fmt.Fprint(w, `{"response":"ok","done":true}`)
+	}))
+	defer srv.Close()
+
+	c := NewClient(WithBaseURL(srv.URL), WithPriorityQueue(1))
+	var wg sync.WaitGroup
+	send := func(ctx context.Context, prompt string) {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if _, err := c.Generate(ctx, GenerateRequest{Model: "m", Prompt: prompt}); err != nil {
+				t.Errorf("%s: %v", prompt, err)
+			}
+		}()
+		// Let the request reach the queue before the next one
+		time.Sleep(50 * time.Millisecond)
+	}
+
+	// The first request holds the only slot while the others queue
+	send(context.Background(), "first")
+	send(ContextWithPriority(context.Background(), PriorityLow), "low")
+	send(context.Background(), "normal")
+	send(ContextWithPriority(context.Background(), PriorityHigh), "high")
+	close(release)
+	wg.Wait()
+
+	want := []string{"first", "high", "normal", "low"}
+	if !slices.Equal(order, want) {
+		t.Errorf("dispatch order = %v, want %v", order, want)
+	}
+}
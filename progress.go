@@ -0,0 +1,179 @@
+// progress.go
+package ollamago
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+// TransferStats summarizes the progress of a pull or push across all layers
+type TransferStats struct {
+	Status         string
+	Digest         string
+	CompletedBytes int64
+	TotalBytes     int64
+	Percent        float64
+	BytesPerSecond float64
+	// ETA is the estimated time remaining, zero when unknown
+	ETA time.Duration
+	// Done is set on the final success frame
+	Done bool
+}
+
+// speedSmoothing weights the latest speed sample in the moving average
+const speedSmoothing = 0.3
+
+// transferTracker aggregates per-layer progress into overall statistics
+type transferTracker struct {
+	layers    map[string]*layerProgress
+	order     []string
+	lastBytes int64
+	lastTime  time.Time
+	speed     float64
+}
+
+type layerProgress struct {
+	completed int64
+	total     int64
+}
+
+func newTransferTracker() *transferTracker {
+	return &transferTracker{layers: make(map[string]*layerProgress)}
+}
+
+// update records a progress frame received at now and returns the overall
+// statistics so far
+func (t *transferTracker) update(p ProgressResponse, now time.Time) TransferStats {
+	if p.Digest != "" && p.Total > 0 {
+		layer, ok := t.layers[p.Digest]
+		if !ok {
+			layer = &layerProgress{}
+			t.layers[p.Digest] = layer
+			t.order = append(t.order, p.Digest)
+		}
+		layer.total = p.Total
+		if p.Completed > layer.completed {
+			layer.completed = p.Completed
+		}
+	}
+
+	stats := TransferStats{
+		Status: p.Status,
+		Digest: p.Digest,
+		Done:   p.Status == "success",
+	}
+	for _, digest := range t.order {
+		layer := t.layers[digest]
+		stats.CompletedBytes += layer.completed
+		stats.TotalBytes += layer.total
+	}
+
+	if !t.lastTime.IsZero() {
+		if elapsed := now.Sub(t.lastTime).Seconds(); elapsed > 0 {
+			sample := float64(stats.CompletedBytes-t.lastBytes) / elapsed
+			if t.speed == 0 {
+				t.speed = sample
+			} else {
+				t.speed = speedSmoothing*sample + (1-speedSmoothing)*t.speed
+			}
+		}
+	}
+	if now.After(t.lastTime) || t.lastTime.IsZero() {
+		t.lastTime = now
+		t.lastBytes = stats.CompletedBytes
+	}
+	stats.BytesPerSecond = t.speed
+
+	switch {
+	case stats.Done:
+		stats.Percent = 100
+	case stats.TotalBytes > 0:
+		stats.Percent = float64(stats.CompletedBytes) / float64(stats.TotalBytes) * 100
+	}
+	if !stats.Done && t.speed > 0 && stats.TotalBytes > stats.CompletedBytes {
+		remaining := float64(stats.TotalBytes - stats.CompletedBytes)
+		stats.ETA = time.Duration(remaining / t.speed * float64(time.Second))
+	}
+
+	return stats
+}
+
+// TransferProgress aggregates the progress frames of PullModelStream or
+// PushModelStream into overall transfer statistics. The returned channel is
+// closed when progress is closed or ctx is done, so pass the context of the
+// stream to stop both together.
+func TransferProgress(ctx context.Context, progress <-chan ProgressResponse) <-chan TransferStats {
+	out := make(chan TransferStats)
+
+	go func() {
+		defer close(out)
+
+		tracker := newTransferTracker()
+		for {
+			var p ProgressResponse
+			var ok bool
+			select {
+			case p, ok = <-progress:
+				if !ok {
+					return
+				}
+			case <-ctx.Done():
+				return
+			}
+			select {
+			case out <- tracker.update(p, time.Now()):
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+
+	return out
+}
+
+// PullProgress is the overall progress of a model download
+type PullProgress = TransferStats
+
+// PullModelProgress pulls a model and reports its overall progress across
+// all layers, for a single progress bar. An error reported by the server
+// in a progress frame ends the stream and is delivered on the error channel.
+func (c *Client) PullModelProgress(ctx context.Context, req PullModelRequest) (<-chan PullProgress, <-chan error) {
+	out := make(chan PullProgress)
+	errChan := make(chan error, 1)
+
+	ctx, cancel := context.WithCancel(ctx)
+	respChan, streamErrs := c.PullModelStream(ctx, req)
+
+	go func() {
+		defer cancel()
+		defer close(errChan)
+		defer close(out)
+
+		tracker := newTransferTracker()
+		var pullErr error
+		for p := range respChan {
+			if pullErr != nil {
+				continue
+			}
+			if p.Error != "" {
+				pullErr = fmt.Errorf("pulling %s: %s", req.Name, p.Error)
+				cancel()
+				continue
+			}
+			select {
+			case out <- tracker.update(p, time.Now()):
+			case <-ctx.Done():
+			}
+		}
+
+		if err := <-streamErrs; pullErr == nil {
+			pullErr = err
+		}
+		if pullErr != nil {
+			errChan <- pullErr
+		}
+	}()
+
+	return out, errChan
+}
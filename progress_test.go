@@ -0,0 +1,83 @@
+// progress_test.go
+package ollamago
+
+import (
+	"context"
+	"math"
+	"testing"
+	"time"
+)
+
+func TestTransferTracker(t *testing.T) {
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	frames := []struct {
+		at       time.Duration
+		progress ProgressResponse
+		want     TransferStats
+	}{
+		{
+			at:       0,
+			progress: ProgressResponse{Status: "pulling a", Digest: "a", Total: 1000},
+			want:     TransferStats{Status: "pulling a", Digest: "a", TotalBytes: 1000},
+		},
+		{
+			at:       time.Second,
+			progress: ProgressResponse{Status: "pulling a", Digest: "a", Total: 1000, Completed: 100},
+			// The first sample seeds the average
+			want: TransferStats{Status: "pulling a", Digest: "a", CompletedBytes: 100, TotalBytes: 1000, Percent: 10, BytesPerSecond: 100, ETA: 9 * time.Second},
+		},
+		{
+			at:       2 * time.Second,
+			progress: ProgressResponse{Status: "pulling b", Digest: "b", Total: 1000, Completed: 300},
+			// 0.3*300 + 0.7*100 = 160 bytes per second over 1600 remaining
+			want: TransferStats{Status: "pulling b", Digest: "b", CompletedBytes: 400, TotalBytes: 2000, Percent: 20, BytesPerSecond: 160, ETA: 10 * time.Second},
+		},
+		{
+			at:       3 * time.Second,
+			progress: ProgressResponse{Status: "pulling b", Digest: "b", Total: 1000, Completed: 780},
+			// 0.3*480 + 0.7*160 = 256 bytes per second over 1120 remaining
+			want: TransferStats{Status: "pulling b", Digest: "b", CompletedBytes: 880, TotalBytes: 2000, Percent: 44, BytesPerSecond: 256, ETA: 4375 * time.Millisecond},
+		},
+		{
+			at:       4 * time.Second,
+			progress: ProgressResponse{Status: "success"},
+			// No new bytes: 0.7*256, and no ETA once done
+			want: TransferStats{Status: "success", CompletedBytes: 880, TotalBytes: 2000, Percent: 100, BytesPerSecond: 179.2, Done: true},
+		},
+	}
+
+	tracker := newTransferTracker()
+	for _, f := range frames {
+		got := tracker.update(f.progress, start.Add(f.at))
+		if math.Abs(got.BytesPerSecond-f.want.BytesPerSecond) < 1e-9 {
+			got.BytesPerSecond = f.want.BytesPerSecond
+		}
+		if (got.ETA - f.want.ETA).Abs() < time.Microsecond {
+			got.ETA = f.want.ETA
+		}
+		if got != f.want {
+			t.Errorf("at %v: stats = %+v, want %+v", f.at, got, f.want)
+		}
+	}
+}
+
+func TestTransferProgress(t *testing.T) {
+	progress := make(chan ProgressResponse, 3)
+	progress <- ProgressResponse{Status: "pulling a", Digest: "a", Total: 10, Completed: 5}
+	progress <- ProgressResponse{Status: "pulling a", Digest: "a", Total: 10, Completed: 10}
+	progress <- ProgressResponse{Status: "success"}
+	close(progress)
+
+	var last TransferStats
+	n := 0
+	for stats := range TransferProgress(context.Background(), progress) {
+		last = stats
+		n++
+	}
+	if n != 3 {
+		t.Errorf("got %d stats, want 3", n)
+	}
+	if !last.Done || last.Percent != 100 || last.CompletedBytes != 10 {
+		t.Errorf("final stats = %+v", last)
+	}
+}
@@ -0,0 +1,83 @@
+// ratelimit.go
+package ollamago
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// rateLimiter is a token bucket refilled at rate tokens per second up to
+// burst tokens. Waiters reserve a token up front, so they are served in
+// arrival order.
+type rateLimiter struct {
+	rate  float64
+	burst float64
+
+	mu     sync.Mutex
+	tokens float64
+	last   time.Time
+}
+
+func newRateLimiter(rps float64, burst int) *rateLimiter {
+	if burst < 1 {
+		burst = 1
+	}
+	return &rateLimiter{rate: rps, burst: float64(burst), tokens: float64(burst)}
+}
+
+// WithRateLimit caps the client at rps requests per second, allowing bursts
+// of up to burst requests. Every request, streaming or not and including
+// each retry, waits for its turn before it is sent, or fails with the
+// context's error if the context ends first. A non-positive rps disables
+// the limit.
+func WithRateLimit(rps float64, burst int) Option {
+	return func(c *Client) {
+		if rps <= 0 {
+			c.limiter = nil
+			return
+		}
+		c.limiter = newRateLimiter(rps, burst)
+	}
+}
+
+// reserve takes a token and returns how long to wait before using it
+func (l *rateLimiter) reserve(now time.Time) time.Duration {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if !l.last.IsZero() {
+		l.tokens = min(l.burst, l.tokens+now.Sub(l.last).Seconds()*l.rate)
+	}
+	l.last = now
+	l.tokens--
+	if l.tokens >= 0 {
+		return 0
+	}
+	return time.Duration(-l.tokens / l.rate * float64(time.Second))
+}
+
+// cancel returns a reserved token that will not be used
+func (l *rateLimiter) cancel() {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.tokens = min(l.burst, l.tokens+1)
+}
+
+// wait blocks until a request may be sent
+func (l *rateLimiter) wait(ctx context.Context) error {
+	delay := l.reserve(time.Now())
+	if delay == 0 {
+		return nil
+	}
+
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		l.cancel()
+		return ctx.Err()
+	}
+}
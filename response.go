@@ -0,0 +1,149 @@
+// response.go
+package ollamago
+
+import (
+	"time"
+)
+
+// HasContext reports whether the response carries a context that can be
+// passed to the next GenerateRequest to continue the conversation. Only the
+// final response has one: streamed chunks before done never do, and neither
+// do responses to Raw requests.
+func (r *GenerateResponse) HasContext() bool {
+	return len(r.Context) > 0
+}
+
+// CreatedAtTime returns CreatedAt parsed as a time, or the zero time when it
+// is missing or malformed
+func (r *GenerateResponse) CreatedAtTime() time.Time {
+	return parseCreatedAt(r.CreatedAt)
+}
+
+// TotalDurationAsDuration returns the total time spent on the request
+func (r *GenerateResponse) TotalDurationAsDuration() time.Duration {
+	return time.Duration(r.TotalDuration)
+}
+
+// LoadDurationAsDuration returns the time spent loading the model
+func (r *GenerateResponse) LoadDurationAsDuration() time.Duration {
+	return time.Duration(r.LoadDuration)
+}
+
+// PromptEvalDurationAsDuration returns the time spent evaluating the prompt
+func (r *GenerateResponse) PromptEvalDurationAsDuration() time.Duration {
+	return time.Duration(r.PromptEvalDuration)
+}
+
+// EvalDurationAsDuration returns the time spent generating the response
+func (r *GenerateResponse) EvalDurationAsDuration() time.Duration {
+	return time.Duration(r.EvalDuration)
+}
+
+// TokensPerSecond returns the generation speed, or zero when the response
+// carries no timing information
+func (r *GenerateResponse) TokensPerSecond() float64 {
+	return tokensPerSecond(r.EvalCount, r.EvalDuration)
+}
+
+// CreatedAtTime returns CreatedAt parsed as a time, or the zero time when it
+// is missing or malformed
+func (r *ChatResponse) CreatedAtTime() time.Time {
+	return parseCreatedAt(r.CreatedAt)
+}
+
+// TotalDurationAsDuration returns the total time spent on the request
+func (r *ChatResponse) TotalDurationAsDuration() time.Duration {
+	return time.Duration(r.TotalDuration)
+}
+
+// LoadDurationAsDuration returns the time spent loading the model
+func (r *ChatResponse) LoadDurationAsDuration() time.Duration {
+	return time.Duration(r.LoadDuration)
+}
+
+// PromptEvalDurationAsDuration returns the time spent evaluating the prompt
+func (r *ChatResponse) PromptEvalDurationAsDuration() time.Duration {
+	return time.Duration(r.PromptEvalDuration)
+}
+
+// EvalDurationAsDuration returns the time spent generating the response
+func (r *ChatResponse) EvalDurationAsDuration() time.Duration {
+	return time.Duration(r.EvalDuration)
+}
+
+// TokensPerSecond returns the generation speed, or zero when the response
+// carries no timing information
+func (r *ChatResponse) TokensPerSecond() float64 {
+	return tokensPerSecond(r.EvalCount, r.EvalDuration)
+}
+
+// parseCreatedAt parses an RFC 3339 timestamp with optional fractional seconds
+func parseCreatedAt(s string) time.Time {
+	t, err := time.Parse(time.RFC3339Nano, s)
+	if err != nil {
+		return time.Time{}
+	}
+	return t
+}
+
+// tokensPerSecond computes a token rate from a count and nanosecond duration
+func tokensPerSecond(count int, duration int64) float64 {
+	if duration <= 0 {
+		return 0
+	}
+	return float64(count) / time.Duration(duration).Seconds()
+}
+
+// FinishInfo describes how a generation ended
+type FinishInfo struct {
+	// Reason is the done_reason reported by the server, or the one inferred
+	// for servers that do not report it
+	Reason string
+	// Complete is set when the model finished on its own, either at its end
+	// of turn or at a stop sequence; the server does not tell them apart
+	Complete bool
+	// Truncated is set when the generation hit the num_predict limit or the
+	// context length
+	Truncated bool
+	// Load is set for requests that only loaded or unloaded the model
+	Load bool
+	// Incomplete is set when the response is not the final one of a stream
+	Incomplete bool
+
+	PromptTokens     int
+	CompletionTokens int
+}
+
+// Finish classifies how the generation ended from the done reason. For
+// servers that report no reason it compares the token count with
+// num_predict, which needs the request from WithEchoRequest.
+func (r *GenerateResponse) Finish() FinishInfo {
+	info := FinishInfo{
+		Reason:           r.DoneReason,
+		PromptTokens:     r.PromptEvalCount,
+		CompletionTokens: r.EvalCount,
+	}
+	if !r.Done {
+		info.Incomplete = true
+		return info
+	}
+
+	if info.Reason == "" {
+		info.Reason = "stop"
+		if r.Request != nil && r.Request.Options != nil && r.Request.Options.NumPredict != nil {
+			if limit := *r.Request.Options.NumPredict; limit > 0 && r.EvalCount >= limit {
+				info.Reason = "length"
+			}
+		}
+	}
+
+	switch {
+	case isLoadReason(info.Reason):
+		info.Load = true
+	case info.Reason == "length":
+		info.Truncated = true
+	default:
+		info.Complete = true
+	}
+	return info
+}
@@ -0,0 +1,58 @@
+// response_test.go
+package ollamago
+
+import (
+	"testing"
+)
+
+func TestFinish(t *testing.T) {
+	limited := &GenerateRequest{Model: "m", Options: &Options{NumPredict: ptr(10)}}
+	tests := []struct {
+		name string
+		resp GenerateResponse
+		want FinishInfo
+	}{
+		{
+			name: "stop",
+			resp: GenerateResponse{Done: true, DoneReason: "stop", EvalCount: 4},
+			want: FinishInfo{Reason: "stop", Complete: true, CompletionTokens: 4},
+		},
+		{
+			name: "length",
+			resp: GenerateResponse{Done: true, DoneReason: "length", PromptEvalCount: 3, EvalCount: 10},
+			want: FinishInfo{Reason: "length", Truncated: true, PromptTokens: 3, CompletionTokens: 10},
+		},
+		{
+			name: "natural end without reason",
+			resp: GenerateResponse{Done: true, EvalCount: 4, Request: limited},
+			want: FinishInfo{Reason: "stop", Complete: true, CompletionTokens: 4},
+		},
+		{
+			name: "length inferred without reason",
+			resp: GenerateResponse{Done: true, EvalCount: 10, Request: limited},
+			want: FinishInfo{Reason: "length", Truncated: true, CompletionTokens: 10},
+		},
+		{
+			name: "no reason and no request",
+			resp: GenerateResponse{Done: true, EvalCount: 10},
+			want: FinishInfo{Reason: "stop", Complete: true, CompletionTokens: 10},
+		},
+		{
+			name: "load",
+			resp: GenerateResponse{Done: true, DoneReason: "load"},
+			want: FinishInfo{Reason: "load", Load: true},
+		},
+		{
+			name: "stream chunk",
+			resp: GenerateResponse{Response: "partial"},
+			want: FinishInfo{Incomplete: true},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.resp.Finish(); got != tt.want {
+				t.Errorf("Finish = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,92 @@
+// retry.go
+package ollamago
+
+import (
+	"context"
+	"errors"
+	"math/rand"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// retryPolicy configures automatic retries of transient failures
+type retryPolicy struct {
+	maxAttempts int
+	backoff     time.Duration
+}
+
+// maxBackoff caps the delay between two attempts
+const maxBackoff = 30 * time.Second
+
+// idempotentPaths lists the POST endpoints that can be repeated safely
+var idempotentPaths = map[string]bool{
+	"/api/generate":   true,
+	"/api/chat":       true,
+	"/api/embed":      true,
+	"/api/embeddings": true,
+	"/api/show":       true,
+	"/api/pull":       true,
+}
+
+// delay returns the time to wait after the given failed attempt: the base
+// backoff doubled per attempt, with up to 50% random jitter
+func (p *retryPolicy) delay(attempt int) time.Duration {
+	d := p.backoff
+	for i := 1; i < attempt && d < maxBackoff; i++ {
+		d *= 2
+	}
+	if d > maxBackoff {
+		d = maxBackoff
+	}
+	if d > 0 {
+		d += time.Duration(rand.Int63n(int64(d)/2 + 1))
+	}
+	return d
+}
+
+// shouldRetry reports whether a failed attempt should be repeated
+func (c *Client) shouldRetry(ctx context.Context, method, path string, attempt int, resp *http.Response, err error) bool {
+	if c.retry == nil || attempt >= c.retry.maxAttempts || ctx.Err() != nil {
+		return false
+	}
+	if method != http.MethodGet && method != http.MethodHead && !idempotentPaths[path] {
+		return false
+	}
+	if err != nil {
+		var transportErr *transportError
+		return errors.As(err, &transportErr)
+	}
+	return resp.StatusCode >= 500 || resp.StatusCode == http.StatusTooManyRequests
+}
+
+// transportError is a failure of the HTTP round trip itself, such as a
+// refused connection, as opposed to an error preparing the request. Only
+// these are retried.
+type transportError struct {
+	err error
+}
+
+func (e *transportError) Error() string {
+	return "making request: " + e.err.Error()
+}
+
+func (e *transportError) Unwrap() error {
+	return e.err
+}
+
+// parseRetryAfter reads a Retry-After header given in seconds or as an HTTP
+// date
+func parseRetryAfter(header http.Header, now time.Time) (time.Duration, bool) {
+	value := header.Get("Retry-After")
+	if value == "" {
+		return 0, false
+	}
+	if secs, err := strconv.Atoi(value); err == nil && secs >= 0 {
+		return time.Duration(secs) * time.Second, true
+	}
+	if t, err := http.ParseTime(value); err == nil {
+		return max(t.Sub(now), 0), true
+	}
+	return 0, false
+}
@@ -0,0 +1,93 @@
+// retry_test.go
+package ollamago
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestRetryOnlyTransportErrors(t *testing.T) {
+	closed := httptest.NewServer(http.NotFoundHandler())
+	closed.Close()
+
+	tests := []struct {
+		name      string
+		authErr   error
+		wantTries int
+	}{
+		{"connection refused", nil, 3},
+		{"auth failure", errors.New("no credentials"), 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tries := 0
+			c := NewClient(
+				WithBaseURL(closed.URL),
+				WithRetry(3, time.Millisecond),
+				WithAuthFunc(func(*http.Request) error {
+					tries++
+					return tt.authErr
+				}),
+			)
+			if _, err := c.ListModels(context.Background()); err == nil {
+				t.Fatal("expected an error")
+			}
+			if tries != tt.wantTries {
+				t.Errorf("attempts = %d, want %d", tries, tt.wantTries)
+			}
+		})
+	}
+}
+
+func TestRequestBodyHook(t *testing.T) {
+	attempts := 0
+	var received []byte
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		attempts++
+		received, _ = io.ReadAll(r.Body)
+		w.Header().Set("Content-Type", "application/json")
+		if attempts == 1 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			fmt.Fprint(w, `{"error":"busy"}`)
+			return
+		}
+		fmt.Fprint(w, `{"response":"ok","done":true}`)
+	}))
+	defer srv.Close()
+
+	type call struct {
+		endpoint string
+		body     []byte
+	}
+	var calls []call
+	c := NewClient(
+		WithBaseURL(srv.URL),
+		WithRetry(3, time.Millisecond),
+		WithRequestBodyHook(func(endpoint string, body []byte) {
+			calls = append(calls, call{endpoint, append([]byte(nil), body...)})
+		}),
+	)
+
+	if _, err := c.Generate(context.Background(), GenerateRequest{Model: "m", Prompt: "hi"}); err != nil {
+		t.Fatal(err)
+	}
+	if attempts != 2 {
+		t.Fatalf("%d attempts, want a retry", attempts)
+	}
+	if len(calls) != 1 {
+		t.Fatalf("hook ran %d times, want once", len(calls))
+	}
+	if calls[0].endpoint != "/api/generate" {
+		t.Errorf("endpoint = %q", calls[0].endpoint)
+	}
+	if !bytes.Equal(calls[0].body, received) {
+		t.Errorf("hook saw %s, server received %s", calls[0].body, received)
+	}
+}
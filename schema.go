@@ -0,0 +1,438 @@
+// schema.go
+package ollamago
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var (
+	timeType       = reflect.TypeOf(time.Time{})
+	rawMessageType = reflect.TypeOf(json.RawMessage{})
+)
+
+// JSONSchema reflects a Go value's type into a JSON schema suitable for
+// GenerateRequest.FormatSchema or ChatRequest.FormatSchema. Struct fields
+// follow their json tags, and fields without omitempty are required. A
+// jsonschema tag adds a description, enum values or bounds, or makes a
+// field required:
+//
+//	Mood  string `json:"mood" jsonschema:"description=Overall tone,enum=happy|sad"`
+//	Score int    `json:"score,omitempty" jsonschema:"minimum=0,maximum=10,required"`
+func JSONSchema(v any) (json.RawMessage, error) {
+	t := reflect.TypeOf(v)
+	if t == nil {
+		return nil, fmt.Errorf("cannot build schema for nil")
+	}
+
+	schema, err := schemaFor(t, make(map[reflect.Type]bool))
+	if err != nil {
+		return nil, err
+	}
+	return json.Marshal(schema)
+}
+
+// schemaFor builds the schema for a type. seen guards against recursive
+// struct types, which cannot be expressed without references.
+func schemaFor(t reflect.Type, seen map[reflect.Type]bool) (map[string]interface{}, error) {
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+
+	switch t {
+	case timeType:
+		return map[string]interface{}{"type": "string", "format": "date-time"}, nil
+	case rawMessageType:
+		return map[string]interface{}{}, nil
+	}
+
+	switch t.Kind() {
+	case reflect.Bool:
+		return map[string]interface{}{"type": "boolean"}, nil
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return map[string]interface{}{"type": "integer"}, nil
+	case reflect.Float32, reflect.Float64:
+		return map[string]interface{}{"type": "number"}, nil
+	case reflect.String:
+		return map[string]interface{}{"type": "string"}, nil
+	case reflect.Interface:
+		return map[string]interface{}{}, nil
+	case reflect.Slice, reflect.Array:
+		if t.Elem().Kind() == reflect.Uint8 {
+			// encoding/json writes byte slices as base64 strings
+			return map[string]interface{}{"type": "string"}, nil
+		}
+		items, err := schemaFor(t.Elem(), seen)
+		if err != nil {
+			return nil, err
+		}
+		return map[string]interface{}{"type": "array", "items": items}, nil
+	case reflect.Map:
+		if t.Key().Kind() != reflect.String {
+			return nil, fmt.Errorf("unsupported map key type %s", t.Key())
+		}
+		values, err := schemaFor(t.Elem(), seen)
+		if err != nil {
+			return nil, err
+		}
+		return map[string]interface{}{"type": "object", "additionalProperties": values}, nil
+	case reflect.Struct:
+		if seen[t] {
+			return nil, fmt.Errorf("recursive type %s is not supported", t)
+		}
+		seen[t] = true
+		defer delete(seen, t)
+
+		properties := make(map[string]interface{})
+		required := []string{}
+		if err := structProperties(t, seen, properties, &required); err != nil {
+			return nil, err
+		}
+		return map[string]interface{}{
+			"type":       "object",
+			"properties": properties,
+			"required":   required,
+		}, nil
+	default:
+		return nil, fmt.Errorf("unsupported type %s", t)
+	}
+}
+
+// applySchemaTag adds the constraints of a jsonschema struct tag to a field
+// schema and reports whether the tag marks the field required. The tag is a
+// comma-separated list of description=..., enum=a|b|c, minimum=n, maximum=n
+// and required; a description may itself contain commas.
+func applySchemaTag(schema map[string]interface{}, tag string) (required bool, err error) {
+	if tag == "" {
+		return false, nil
+	}
+
+	var parts []string
+	for _, part := range strings.Split(tag, ",") {
+		key, _, hasValue := strings.Cut(part, "=")
+		known := key == "description" || key == "enum" || key == "minimum" || key == "maximum"
+		if len(parts) > 0 && !(hasValue && known) && part != "required" {
+			// A comma inside the previous value
+			parts[len(parts)-1] += "," + part
+			continue
+		}
+		parts = append(parts, part)
+	}
+
+	for _, part := range parts {
+		key, value, _ := strings.Cut(part, "=")
+		switch key {
+		case "required":
+			required = true
+		case "description":
+			schema["description"] = value
+		case "enum":
+			var enum []interface{}
+			for _, v := range strings.Split(value, "|") {
+				ev, err := schemaTagValue(schema["type"], v)
+				if err != nil {
+					return false, fmt.Errorf("enum value %q: %w", v, err)
+				}
+				enum = append(enum, ev)
+			}
+			schema["enum"] = enum
+		case "minimum", "maximum":
+			n, err := strconv.ParseFloat(value, 64)
+			if err != nil {
+				return false, fmt.Errorf("%s %q is not a number", key, value)
+			}
+			schema[key] = n
+		default:
+			return false, fmt.Errorf("unknown jsonschema tag option %q", key)
+		}
+	}
+	return required, nil
+}
+
+// schemaTagValue converts an enum value from a tag to the field's type
+func schemaTagValue(typ interface{}, v string) (interface{}, error) {
+	switch typ {
+	case "integer":
+		return strconv.ParseInt(v, 10, 64)
+	case "number":
+		return strconv.ParseFloat(v, 64)
+	case "boolean":
+		return strconv.ParseBool(v)
+	}
+	return v, nil
+}
+
+// structProperties adds the schema of each exported field of t, flattening
+// embedded structs the way encoding/json does
+func structProperties(t reflect.Type, seen map[reflect.Type]bool, properties map[string]interface{}, required *[]string) error {
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		tag := field.Tag.Get("json")
+		if tag == "-" {
+			continue
+		}
+		name, opts, _ := strings.Cut(tag, ",")
+
+		if field.Anonymous && name == "" {
+			ft := field.Type
+			if ft.Kind() == reflect.Ptr {
+				ft = ft.Elem()
+			}
+			if ft.Kind() == reflect.Struct {
+				if err := structProperties(ft, seen, properties, required); err != nil {
+					return err
+				}
+				continue
+			}
+		}
+		if !field.IsExported() {
+			continue
+		}
+		if name == "" {
+			name = field.Name
+		}
+
+		schema, err := schemaFor(field.Type, seen)
+		if err != nil {
+			return fmt.Errorf("field %s: %w", field.Name, err)
+		}
+		forceRequired, err := applySchemaTag(schema, field.Tag.Get("jsonschema"))
+		if err != nil {
+			return fmt.Errorf("field %s: %w", field.Name, err)
+		}
+		properties[name] = schema
+		if forceRequired || !strings.Contains(opts, "omitempty") {
+			*required = append(*required, name)
+		}
+	}
+	return nil
+}
+
+// validateSchema checks a decoded JSON value against the subset of JSON
+// schema that JSONSchema produces and tool definitions commonly use: type,
+// properties, required, additionalProperties, items, enum, minimum and
+// maximum. Numbers must be decoded as json.Number. Unknown keywords are
+// ignored.
+func validateSchema(schema map[string]interface{}, v interface{}, path string) error {
+	if t, ok := schema["type"]; ok {
+		if err := checkType(t, v, path); err != nil {
+			return err
+		}
+	}
+
+	if enum, ok := schema["enum"].([]interface{}); ok {
+		if !inEnum(enum, v) {
+			return fmt.Errorf("%s: value %v is not one of %v", path, jsonValue(v), jsonValue(enum))
+		}
+	}
+
+	switch v := v.(type) {
+	case json.Number:
+		f, err := v.Float64()
+		if err != nil {
+			return fmt.Errorf("%s: invalid number %s", path, v)
+		}
+		if min, ok := schemaNumber(schema["minimum"]); ok && f < min {
+			return fmt.Errorf("%s: %s is less than the minimum %v", path, v, min)
+		}
+		if max, ok := schemaNumber(schema["maximum"]); ok && f > max {
+			return fmt.Errorf("%s: %s is greater than the maximum %v", path, v, max)
+		}
+	case map[string]interface{}:
+		if required, ok := schema["required"].([]interface{}); ok {
+			for _, name := range required {
+				name, _ := name.(string)
+				if _, ok := v[name]; !ok {
+					return fmt.Errorf("%s: missing required property %q", path, name)
+				}
+			}
+		}
+		properties, _ := schema["properties"].(map[string]interface{})
+		for name, value := range v {
+			if prop, ok := properties[name].(map[string]interface{}); ok {
+				if err := validateSchema(prop, value, path+"."+name); err != nil {
+					return err
+				}
+				continue
+			}
+			switch extra := schema["additionalProperties"].(type) {
+			case bool:
+				if !extra {
+					return fmt.Errorf("%s: unexpected property %q", path, name)
+				}
+			case map[string]interface{}:
+				if err := validateSchema(extra, value, path+"."+name); err != nil {
+					return err
+				}
+			}
+		}
+	case []interface{}:
+		if items, ok := schema["items"].(map[string]interface{}); ok {
+			for i, item := range v {
+				if err := validateSchema(items, item, fmt.Sprintf("%s[%d]", path, i)); err != nil {
+					return err
+				}
+			}
+		}
+	}
+	return nil
+}
+
+// checkType checks a value against a schema type, which is a name or a list
+// of names
+func checkType(t interface{}, v interface{}, path string) error {
+	var names []string
+	switch t := t.(type) {
+	case string:
+		names = []string{t}
+	case []interface{}:
+		for _, name := range t {
+			if name, ok := name.(string); ok {
+				names = append(names, name)
+			}
+		}
+	default:
+		return nil
+	}
+
+	for _, name := range names {
+		if hasType(name, v) {
+			return nil
+		}
+	}
+	return fmt.Errorf("%s: expected %s, got %s", path, strings.Join(names, " or "), jsonType(v))
+}
+
+// hasType reports whether a decoded JSON value has the named schema type
+func hasType(name string, v interface{}) bool {
+	switch name {
+	case "integer":
+		n, ok := v.(json.Number)
+		if !ok {
+			return false
+		}
+		f, err := n.Float64()
+		return err == nil && f == float64(int64(f))
+	case "number":
+		_, ok := v.(json.Number)
+		return ok
+	default:
+		return jsonType(v) == name
+	}
+}
+
+// jsonType names the JSON type of a decoded value
+func jsonType(v interface{}) string {
+	switch v.(type) {
+	case nil:
+		return "null"
+	case bool:
+		return "boolean"
+	case json.Number:
+		return "number"
+	case string:
+		return "string"
+	case []interface{}:
+		return "array"
+	case map[string]interface{}:
+		return "object"
+	default:
+		return fmt.Sprintf("%T", v)
+	}
+}
+
+// inEnum reports whether v equals one of the enum values
+func inEnum(enum []interface{}, v interface{}) bool {
+	for _, e := range enum {
+		if jsonEqual(e, v) {
+			return true
+		}
+	}
+	return false
+}
+
+// jsonEqual reports whether two decoded JSON values are equal. Numbers are
+// compared by value rather than by their text, so 1 and 1.0 are equal.
+func jsonEqual(a, b interface{}) bool {
+	if x, ok := jsonNumber(a); ok {
+		y, ok := jsonNumber(b)
+		return ok && x.Cmp(y) == 0
+	}
+
+	switch a := a.(type) {
+	case map[string]interface{}:
+		b, ok := b.(map[string]interface{})
+		if !ok || len(a) != len(b) {
+			return false
+		}
+		for key, value := range a {
+			other, ok := b[key]
+			if !ok || !jsonEqual(value, other) {
+				return false
+			}
+		}
+		return true
+	case []interface{}:
+		b, ok := b.([]interface{})
+		if !ok || len(a) != len(b) {
+			return false
+		}
+		for i := range a {
+			if !jsonEqual(a[i], b[i]) {
+				return false
+			}
+		}
+		return true
+	default:
+		// nil, booleans and strings
+		return a == b
+	}
+}
+
+// jsonNumber returns the exact value of a number decoded as json.Number or
+// produced by schemaTagValue
+func jsonNumber(v interface{}) (*big.Rat, bool) {
+	var s string
+	switch v := v.(type) {
+	case json.Number:
+		s = v.String()
+	case float64:
+		s = strconv.FormatFloat(v, 'g', -1, 64)
+	case int64:
+		s = strconv.FormatInt(v, 10)
+	default:
+		return nil, false
+	}
+	return new(big.Rat).SetString(s)
+}
+
+// jsonValue renders a decoded value as compact JSON for comparisons and
+// error messages
+func jsonValue(v interface{}) string {
+	b, _ := json.Marshal(v)
+	return string(b)
+}
+
+// schemaNumber reads a numeric schema keyword
+func schemaNumber(v interface{}) (float64, bool) {
+	n, ok := v.(json.Number)
+	if !ok {
+		return 0, false
+	}
+	f, err := n.Float64()
+	return f, err == nil
+}
+
+// decodeJSONNumbers decodes JSON keeping numbers as json.Number
+func decodeJSONNumbers(data []byte, v interface{}) error {
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+	return dec.Decode(v)
+}
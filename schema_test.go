@@ -0,0 +1,130 @@
+// schema_test.go
+package ollamago
+
+import (
+	"encoding/json"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+type schemaAddress struct {
+	City string `json:"city" jsonschema:"description=City name, without the country"`
+	Zip  string `json:"zip,omitempty"`
+}
+
+type schemaPerson struct {
+	Name    string         `json:"name"`
+	Mood    string         `json:"mood" jsonschema:"enum=happy|sad"`
+	Level   int            `json:"level,omitempty" jsonschema:"enum=1|2|3,required"`
+	Score   float64        `json:"score,omitempty" jsonschema:"minimum=0,maximum=10"`
+	Address schemaAddress  `json:"address"`
+	Tags    []string       `json:"tags,omitempty"`
+	Extra   map[string]int `json:"extra,omitempty"`
+	ignored string
+}
+
+func TestJSONSchema(t *testing.T) {
+	raw, err := JSONSchema(schemaPerson{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got map[string]interface{}
+	if err := json.Unmarshal(raw, &got); err != nil {
+		t.Fatal(err)
+	}
+
+	want := map[string]interface{}{
+		"type": "object",
+		"properties": map[string]interface{}{
+			"name":  map[string]interface{}{"type": "string"},
+			"mood":  map[string]interface{}{"type": "string", "enum": []interface{}{"happy", "sad"}},
+			"level": map[string]interface{}{"type": "integer", "enum": []interface{}{1.0, 2.0, 3.0}},
+			"score": map[string]interface{}{"type": "number", "minimum": 0.0, "maximum": 10.0},
+			"address": map[string]interface{}{
+				"type": "object",
+				"properties": map[string]interface{}{
+					"city": map[string]interface{}{"type": "string", "description": "City name, without the country"},
+					"zip":  map[string]interface{}{"type": "string"},
+				},
+				"required": []interface{}{"city"},
+			},
+			"tags":  map[string]interface{}{"type": "array", "items": map[string]interface{}{"type": "string"}},
+			"extra": map[string]interface{}{"type": "object", "additionalProperties": map[string]interface{}{"type": "integer"}},
+		},
+		"required": []interface{}{"name", "mood", "level", "address"},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("JSONSchema =\n%s\nwant\n%v", raw, want)
+	}
+}
+
+func TestJSONSchemaErrors(t *testing.T) {
+	type recursive struct {
+		Next *recursive `json:"next"`
+	}
+	type badEnum struct {
+		N int `json:"n" jsonschema:"enum=one"`
+	}
+	type badOption struct {
+		N int `json:"n" jsonschema:"pattern=x"`
+	}
+	for name, v := range map[string]any{
+		"nil":         nil,
+		"recursive":   recursive{},
+		"bad enum":    badEnum{},
+		"bad option":  badOption{},
+		"channel":     make(chan int),
+		"int map key": map[int]string{},
+	} {
+		if _, err := JSONSchema(v); err == nil {
+			t.Errorf("%s: no error", name)
+		}
+	}
+}
+
+func TestValidateSchema(t *testing.T) {
+	raw, err := JSONSchema(schemaPerson{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var schema map[string]interface{}
+	if err := decodeJSONNumbers(raw, &schema); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name    string
+		value   string
+		wantErr string
+	}{
+		{"valid", `{"name":"Ada","mood":"happy","level":2,"score":9.5,"address":{"city":"London"}}`, ""},
+		{"integral float in enum", `{"name":"Ada","mood":"happy","level":2.0,"address":{"city":"London"}}`, ""},
+		{"exponent in enum", `{"name":"Ada","mood":"happy","level":0.3e1,"address":{"city":"London"}}`, ""},
+		{"not in enum", `{"name":"Ada","mood":"angry","level":2,"address":{"city":"London"}}`, "mood"},
+		{"number not in enum", `{"name":"Ada","mood":"sad","level":4,"address":{"city":"London"}}`, "level"},
+		{"missing required", `{"name":"Ada","mood":"sad","level":1}`, `"address"`},
+		{"nested missing required", `{"name":"Ada","mood":"sad","level":1,"address":{}}`, `"city"`},
+		{"above maximum", `{"name":"Ada","mood":"sad","level":1,"score":11,"address":{"city":"London"}}`, "maximum"},
+		{"below minimum", `{"name":"Ada","mood":"sad","level":1,"score":-1,"address":{"city":"London"}}`, "minimum"},
+		{"wrong type", `{"name":3,"mood":"sad","level":1,"address":{"city":"London"}}`, "expected string"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var v interface{}
+			if err := decodeJSONNumbers([]byte(tt.value), &v); err != nil {
+				t.Fatal(err)
+			}
+			err := validateSchema(schema, v, "value")
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Errorf("err = %v", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("err = %v, want it to mention %s", err, tt.wantErr)
+			}
+		})
+	}
+}
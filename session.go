@@ -0,0 +1,206 @@
+// session.go
+package ollamago
+
+import (
+	"context"
+	"slices"
+	"sync"
+)
+
+// Conversation keeps the message history of a multi-turn chat with one
+// model. It is safe for concurrent use, though concurrent calls to Send
+// interleave their turns.
+type Conversation struct {
+	client *Client
+	model  string
+
+	mu       sync.Mutex
+	messages []Message
+	options  *Options
+	// counted is the number of messages covered by the token counts of the
+	// last response
+	counted          int
+	promptTokens     int
+	completionTokens int
+}
+
+// NewConversation starts an empty conversation with model
+func (c *Client) NewConversation(model string) *Conversation {
+	return &Conversation{client: c, model: model}
+}
+
+// SetOptions sets the model options used by later calls to Send
+func (cv *Conversation) SetOptions(opts *Options) {
+	cv.mu.Lock()
+	defer cv.mu.Unlock()
+	cv.options = opts
+}
+
+// AddSystem appends a system message
+func (cv *Conversation) AddSystem(content string) {
+	cv.Add(Message{Role: "system", Content: content})
+}
+
+// AddUser appends a user message
+func (cv *Conversation) AddUser(content string) {
+	cv.Add(Message{Role: "user", Content: content})
+}
+
+// Add appends a message to the history
+func (cv *Conversation) Add(msg Message) {
+	cv.mu.Lock()
+	defer cv.mu.Unlock()
+	cv.messages = append(cv.messages, msg)
+}
+
+// Send sends the history to the model and appends its reply. The history is
+// left unchanged when the call fails, so it can be retried.
+func (cv *Conversation) Send(ctx context.Context) (*ChatResponse, error) {
+	cv.mu.Lock()
+	req := ChatRequest{
+		Model:    cv.model,
+		Messages: cv.messages,
+		Options:  cv.options,
+	}
+	cv.mu.Unlock()
+
+	// Chat copies the request, so the history is not shared with the call
+	resp, err := cv.client.Chat(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+
+	cv.mu.Lock()
+	defer cv.mu.Unlock()
+	cv.messages = append(cv.messages, resp.Message.clone())
+	cv.counted = len(cv.messages)
+	cv.promptTokens = resp.PromptEvalCount
+	cv.completionTokens = resp.EvalCount
+	return resp, nil
+}
+
+// Messages returns a copy of the history
+func (cv *Conversation) Messages() []Message {
+	cv.mu.Lock()
+	defer cv.mu.Unlock()
+	out := make([]Message, len(cv.messages))
+	for i, msg := range cv.messages {
+		out[i] = msg.clone()
+	}
+	return out
+}
+
+// Usage returns the prompt and completion token counts of the last reply,
+// as reported by the server
+func (cv *Conversation) Usage() (promptTokens, completionTokens int) {
+	cv.mu.Lock()
+	defer cv.mu.Unlock()
+	return cv.promptTokens, cv.completionTokens
+}
+
+// TokenCount estimates the size of the history in tokens: the server's
+// counts for the last exchange plus the client's TokenCounter estimate for
+// messages added since. Compare it with the model's context length to tell
+// when the history needs trimming.
+func (cv *Conversation) TokenCount() int {
+	cv.mu.Lock()
+	defer cv.mu.Unlock()
+
+	n := cv.promptTokens + cv.completionTokens
+	counter := cv.client.TokenCounter()
+	start := cv.counted
+	if start > len(cv.messages) {
+		start = len(cv.messages)
+	}
+	for _, msg := range cv.messages[start:] {
+		n += counter.Count(msg.Content)
+	}
+	return n
+}
+
+// Reset clears the history and token counts
+func (cv *Conversation) Reset() {
+	cv.mu.Lock()
+	defer cv.mu.Unlock()
+	cv.messages = nil
+	cv.counted = 0
+	cv.promptTokens = 0
+	cv.completionTokens = 0
+}
+
+// Trim drops the oldest messages, keeping system messages, until the
+// history fits in maxTokens by the client's TokenCounter estimate. Call it
+// before Send to stay within the model's context window.
+func (cv *Conversation) Trim(maxTokens int) {
+	cv.mu.Lock()
+	defer cv.mu.Unlock()
+
+	trimmed := TrimMessagesWith(cv.client.TokenCounter(), cv.messages, maxTokens, true)
+	if len(trimmed) == len(cv.messages) {
+		return
+	}
+	cv.messages = trimmed
+	// The server's counts no longer describe the history
+	cv.counted = 0
+	cv.promptTokens = 0
+	cv.completionTokens = 0
+}
+
+// GenerateContext chains generate calls to one model by feeding the context
+// returned by each call into the next, the generate endpoint's counterpart
+// to Conversation. It is safe for concurrent use.
+type GenerateContext struct {
+	client *Client
+	model  string
+
+	mu      sync.Mutex
+	context []int
+}
+
+// NewGenerateContext starts a generate chain with model
+func (c *Client) NewGenerateContext(model string) *GenerateContext {
+	return &GenerateContext{client: c, model: model}
+}
+
+// Generate sends prompt with the context of the previous call
+func (g *GenerateContext) Generate(ctx context.Context, prompt string) (*GenerateResponse, error) {
+	return g.GenerateRequest(ctx, GenerateRequest{Prompt: prompt})
+}
+
+// GenerateRequest sends req with the context of the previous call, filling
+// in the model when req has none. A response without a context, such as one
+// to a Raw request, leaves the stored context unchanged.
+func (g *GenerateContext) GenerateRequest(ctx context.Context, req GenerateRequest) (*GenerateResponse, error) {
+	if req.Model == "" {
+		req.Model = g.model
+	}
+	g.mu.Lock()
+	req.Context = g.context
+	g.mu.Unlock()
+
+	resp, err := g.client.Generate(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+
+	if resp.HasContext() {
+		g.mu.Lock()
+		g.context = slices.Clone(resp.Context)
+		g.mu.Unlock()
+	}
+	return resp, nil
+}
+
+// Context returns a copy of the stored context
+func (g *GenerateContext) Context() []int {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	return slices.Clone(g.context)
+}
+
+// Reset discards the stored context so the next call starts afresh
+func (g *GenerateContext) Reset() {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	g.context = nil
+}
@@ -0,0 +1,52 @@
+// sse.go
+package ollamago
+
+import (
+	"bufio"
+	"bytes"
+	"io"
+)
+
+// sseReader converts a server-sent events stream that wraps NDJSON into
+// plain NDJSON, so gateways translating Ollama streams into SSE can be read
+// by the regular stream decoders. Only data fields are kept; event names,
+// ids, comments and the OpenAI style [DONE] marker are dropped.
+type sseReader struct {
+	body    io.ReadCloser
+	scanner *bufio.Scanner
+	buf     []byte
+}
+
+func newSSEReader(body io.ReadCloser, maxLine int) *sseReader {
+	return &sseReader{body: body, scanner: newLineScanner(body, maxLine)}
+}
+
+func (r *sseReader) Read(p []byte) (int, error) {
+	for len(r.buf) == 0 {
+		if !r.scanner.Scan() {
+			if err := r.scanner.Err(); err != nil {
+				return 0, err
+			}
+			return 0, io.EOF
+		}
+
+		line := r.scanner.Bytes()
+		data, ok := bytes.CutPrefix(line, []byte("data:"))
+		if !ok {
+			continue
+		}
+		data = bytes.TrimPrefix(data, []byte(" "))
+		if len(data) == 0 || bytes.Equal(data, []byte("[DONE]")) {
+			continue
+		}
+		r.buf = append(append(r.buf, data...), '\n')
+	}
+
+	n := copy(p, r.buf)
+	r.buf = r.buf[n:]
+	return n, nil
+}
+
+func (r *sseReader) Close() error {
+	return r.body.Close()
+}
@@ -0,0 +1,628 @@
+// stream.go
+package ollamago
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+	"time"
+)
+
+// newLineScanner returns a scanner for line-delimited streams that accepts
+// lines up to maxLine bytes instead of bufio's 64KB default
+func newLineScanner(r io.Reader, maxLine int) *bufio.Scanner {
+	scanner := bufio.NewScanner(r)
+	initial := 64 * 1024
+	if maxLine < initial {
+		initial = maxLine
+	}
+	scanner.Buffer(make([]byte, 0, initial), maxLine)
+	return scanner
+}
+
+// ErrStreamViolation is returned by streaming calls when the client was
+// created with WithStreamAssertions and the stream breaks the protocol
+var ErrStreamViolation = errors.New("stream protocol violation")
+
+// streamAsserter checks streaming invariants. A nil asserter checks nothing.
+type streamAsserter struct {
+	done            bool
+	frames          int
+	promptEvalCount int
+	evalCount       int
+}
+
+// newStreamAsserter returns an asserter when stream assertions are enabled
+func (c *Client) newStreamAsserter() *streamAsserter {
+	if !c.assertions {
+		return nil
+	}
+	return &streamAsserter{}
+}
+
+// frame records a received frame
+func (a *streamAsserter) frame(done bool, promptEvalCount, evalCount int) error {
+	if a == nil {
+		return nil
+	}
+	a.frames++
+	if a.done {
+		return fmt.Errorf("%w: frame %d received after done", ErrStreamViolation, a.frames)
+	}
+	if promptEvalCount < a.promptEvalCount {
+		return fmt.Errorf("%w: prompt_eval_count decreased from %d to %d", ErrStreamViolation, a.promptEvalCount, promptEvalCount)
+	}
+	if evalCount < a.evalCount {
+		return fmt.Errorf("%w: eval_count decreased from %d to %d", ErrStreamViolation, a.evalCount, evalCount)
+	}
+	a.promptEvalCount = promptEvalCount
+	a.evalCount = evalCount
+	a.done = done
+	return nil
+}
+
+// finish checks the stream ended with a done frame
+func (a *streamAsserter) finish() error {
+	if a == nil || a.done {
+		return nil
+	}
+	return fmt.Errorf("%w: stream ended after %d frames without done", ErrStreamViolation, a.frames)
+}
+
+// IndexedChunk is a streamed generate response tagged with the index of the
+// request that produced it
+type IndexedChunk struct {
+	Index    int
+	Response GenerateResponse
+	Err      error
+}
+
+// GenerateMultiStream runs several streaming generations concurrently and
+// interleaves their chunks on a single channel. A failed stream yields one
+// chunk with Err set. The channel is closed once every stream has finished.
+func (c *Client) GenerateMultiStream(ctx context.Context, reqs []GenerateRequest) <-chan IndexedChunk {
+	out := make(chan IndexedChunk)
+
+	var wg sync.WaitGroup
+	for i, req := range reqs {
+		wg.Add(1)
+		go func(index int, req GenerateRequest) {
+			defer wg.Done()
+
+			respChan, errChan := c.GenerateStream(ctx, req)
+			for resp := range respChan {
+				select {
+				case out <- IndexedChunk{Index: index, Response: resp}:
+				case <-ctx.Done():
+					// Drain so the stream goroutine can exit
+					for range respChan {
+					}
+				}
+			}
+			if err := <-errChan; err != nil {
+				select {
+				case out <- IndexedChunk{Index: index, Err: err}:
+				case <-ctx.Done():
+				}
+			}
+		}(i, req)
+	}
+
+	go func() {
+		wg.Wait()
+		close(out)
+	}()
+
+	return out
+}
+
+// GenerateFunc streams a completion and calls fn with each chunk. It returns
+// the first error from the stream or from fn; an error from fn cancels the
+// stream.
+func (c *Client) GenerateFunc(ctx context.Context, req GenerateRequest, fn func(GenerateResponse) error) error {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	respChan, errChan := c.GenerateStream(ctx, req)
+	return consumeStream(respChan, errChan, cancel, fn)
+}
+
+// ChatFunc streams a chat completion and calls fn with each chunk. It
+// returns the first error from the stream or from fn; an error from fn
+// cancels the stream.
+func (c *Client) ChatFunc(ctx context.Context, req ChatRequest, fn func(ChatResponse) error) error {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	respChan, errChan := c.ChatStream(ctx, req)
+	return consumeStream(respChan, errChan, cancel, fn)
+}
+
+// consumeStream passes every value of a stream to fn until fn fails or the
+// stream ends, and returns the terminal error
+func consumeStream[T any](respChan <-chan T, errChan <-chan error, cancel context.CancelFunc, fn func(T) error) error {
+	for resp := range respChan {
+		if err := fn(resp); err != nil {
+			cancel()
+			// Drain so the stream goroutine can exit
+			for range respChan {
+			}
+			return err
+		}
+	}
+	return <-errChan
+}
+
+// ChatStreamSplit streams a chat completion from a reasoning model with the
+// thinking and content deltas on separate channels, so the reasoning can be
+// shown apart from the answer. Both channels must be received from
+// concurrently until closed; the terminal error is then available on errs,
+// as with ChatStream.
+func (c *Client) ChatStreamSplit(ctx context.Context, req ChatRequest) (thinking, content <-chan string, errs <-chan error) {
+	thinkingChan := make(chan string)
+	contentChan := make(chan string)
+	errChan := make(chan error, 1)
+
+	ctx, cancel := context.WithCancel(ctx)
+	respChan, streamErrs := c.ChatStream(ctx, req)
+
+	go func() {
+		defer cancel()
+		defer close(errChan)
+		defer close(contentChan)
+		defer close(thinkingChan)
+
+		for resp := range respChan {
+			if err := sendDelta(ctx, thinkingChan, resp.Message.Thinking); err != nil {
+				break
+			}
+			if err := sendDelta(ctx, contentChan, resp.Message.Content); err != nil {
+				break
+			}
+		}
+		// Drain so the stream goroutine can exit after a cancellation
+		for range respChan {
+		}
+		if err := <-streamErrs; err != nil {
+			errChan <- err
+		} else if err := ctx.Err(); err != nil {
+			errChan <- err
+		}
+	}()
+
+	return thinkingChan, contentChan, errChan
+}
+
+// sendDelta sends a non-empty delta unless the context is done
+func sendDelta(ctx context.Context, ch chan<- string, delta string) error {
+	if delta == "" {
+		return nil
+	}
+	select {
+	case ch <- delta:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// ErrFirstByteTimeout is returned by GenerateStream and ChatStream when the
+// first chunk does not arrive within the first-byte timeout
+var ErrFirstByteTimeout = errors.New("timed out waiting for the first chunk")
+
+// ErrStreamStalled is returned by GenerateStream and ChatStream when no data
+// arrives within the idle timeout after the stream has started
+var ErrStreamStalled = errors.New("stream stalled")
+
+// WithStreamTimeouts bounds how long GenerateStream and ChatStream wait for
+// the first chunk, including loading the model, and for data between
+// chunks once the stream has started, telling a stuck model from a slow one.
+// Zero disables either timeout.
+func WithStreamTimeouts(firstByte, idle time.Duration) Option {
+	return func(c *Client) {
+		c.firstByteTimeout = firstByte
+		c.idleTimeout = idle
+	}
+}
+
+// DefaultTrailingFrameWait is how long GenerateStream and ChatStream wait
+// by default after the done frame for data trailing it
+const DefaultTrailingFrameWait = time.Second
+
+// errTrailingWait ends the wait for data trailing a done frame
+var errTrailingWait = errors.New("done waiting for trailing frames")
+
+// WithTrailingFrameWait sets how long GenerateStream and ChatStream hold the
+// done frame, waiting for the server to end the response, so that any data
+// sent after it is merged into the final chunk. The final chunk is
+// delivered when the response ends or the wait runs out, whichever comes
+// first, so a server that keeps the connection open cannot hold it back.
+// Zero delivers the done frame as soon as it arrives and ignores anything
+// after it. The default is DefaultTrailingFrameWait.
+func WithTrailingFrameWait(d time.Duration) Option {
+	return func(c *Client) {
+		c.trailingWait = max(d, 0)
+	}
+}
+
+// streamWatchdog cancels a stream's context with ErrFirstByteTimeout or
+// ErrStreamStalled when data stops arriving
+type streamWatchdog struct {
+	idle   time.Duration
+	cancel context.CancelCauseFunc
+
+	mu      sync.Mutex
+	timer   *time.Timer
+	started bool
+	stopped bool
+}
+
+// streamContext derives the context of a stream, watched by the client's
+// stream timeouts. The returned stop function releases it and must be
+// called when the stream ends.
+func (c *Client) streamContext(ctx context.Context) (context.Context, *streamWatchdog, func()) {
+	if c.firstByteTimeout <= 0 && c.idleTimeout <= 0 {
+		return ctx, nil, func() {}
+	}
+
+	ctx, cancel := context.WithCancelCause(ctx)
+	w := &streamWatchdog{idle: c.idleTimeout, cancel: cancel}
+	if c.firstByteTimeout > 0 {
+		w.timer = time.AfterFunc(c.firstByteTimeout, w.expire)
+	}
+	return ctx, w, func() {
+		w.stop()
+		cancel(context.Canceled)
+	}
+}
+
+// finished reports whether a stream whose done frame has been read can
+// still deliver it: the caller has not cancelled parent, and ctx was at most
+// cancelled by the idle timeout or the trailing frame wait while the server
+// held the body open
+func finished(parent, ctx context.Context) bool {
+	if parent.Err() != nil {
+		return false
+	}
+	if ctx.Err() == nil {
+		return true
+	}
+	cause := context.Cause(ctx)
+	return errors.Is(cause, ErrStreamStalled) || errors.Is(cause, errTrailingWait)
+}
+
+// expire cancels the stream with the error for its current phase
+func (w *streamWatchdog) expire() {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.stopped {
+		return
+	}
+	if w.started {
+		w.cancel(ErrStreamStalled)
+	} else {
+		w.cancel(ErrFirstByteTimeout)
+	}
+}
+
+// pause stops the timeout while a chunk waits for the consumer, so a slow
+// consumer is not mistaken for a stalled stream. A nil watchdog does
+// nothing.
+func (w *streamWatchdog) pause() {
+	if w == nil {
+		return
+	}
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.timer != nil {
+		w.timer.Stop()
+	}
+}
+
+// progress records that data arrived or was delivered and restarts the idle
+// timeout. A nil watchdog does nothing.
+func (w *streamWatchdog) progress() {
+	if w == nil {
+		return
+	}
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.started = true
+	if w.timer != nil {
+		w.timer.Stop()
+	}
+	if w.idle > 0 && !w.stopped {
+		w.timer = time.AfterFunc(w.idle, w.expire)
+	}
+}
+
+// stop disarms the watchdog
+func (w *streamWatchdog) stop() {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.stopped = true
+	if w.timer != nil {
+		w.timer.Stop()
+	}
+}
+
+// watch wraps a stream body so reads feed the watchdog. A nil watchdog
+// returns the body unchanged.
+func (w *streamWatchdog) watch(body io.ReadCloser) io.ReadCloser {
+	if w == nil {
+		return body
+	}
+	return &watchedBody{ReadCloser: body, w: w}
+}
+
+// watchedBody reports progress to a watchdog on every read that returns data
+type watchedBody struct {
+	io.ReadCloser
+	w *streamWatchdog
+}
+
+func (b *watchedBody) Read(p []byte) (int, error) {
+	n, err := b.ReadCloser.Read(p)
+	if n > 0 {
+		b.w.progress()
+	}
+	return n, err
+}
+
+// GenerateReader streams a completion as a reader over the generated text,
+// for use with io.Copy and friends. Reads return io.EOF when the generation
+// is done, or the stream's error if it fails. Close cancels the request.
+func (c *Client) GenerateReader(ctx context.Context, req GenerateRequest) (io.ReadCloser, error) {
+	if req.Model == "" {
+		return nil, &RequestError{Message: "model is required"}
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	pr, pw := io.Pipe()
+	go func() {
+		err := c.GenerateFunc(ctx, req, func(resp GenerateResponse) error {
+			_, err := io.WriteString(pw, resp.Response)
+			return err
+		})
+		pw.CloseWithError(err)
+	}()
+
+	return &generateReader{PipeReader: pr, cancel: cancel}, nil
+}
+
+// generateReader cancels its generation when closed
+type generateReader struct {
+	*io.PipeReader
+	cancel context.CancelFunc
+}
+
+func (r *generateReader) Close() error {
+	r.cancel()
+	return r.PipeReader.Close()
+}
+
+// GenerateAccumulate streams a completion, calling onChunk with each text
+// delta for live display, and returns the whole response: the concatenated
+// text with the metadata, including Context, of the final frame. onChunk
+// may be nil. When the stream fails the text so far is returned, with
+// Partial set, alongside the error.
+func (c *Client) GenerateAccumulate(ctx context.Context, req GenerateRequest, onChunk func(string)) (*GenerateResponse, error) {
+	var acc generateAccumulator
+	err := c.GenerateFunc(ctx, req, func(chunk GenerateResponse) error {
+		acc.add(chunk)
+		if onChunk != nil && chunk.Response != "" {
+			onChunk(chunk.Response)
+		}
+		return nil
+	})
+	return acc.result(), err
+}
+
+// ChatAccumulate streams a chat completion, calling onChunk with each
+// content delta for live display, and returns the whole response: the
+// assembled message, with the metadata of the final frame. Tool calls
+// streamed in fragments are joined by ID or index into complete calls.
+// onChunk may be nil. When the stream fails the message so far is
+// returned, with Partial set, alongside the error.
+func (c *Client) ChatAccumulate(ctx context.Context, req ChatRequest, onChunk func(string)) (*ChatResponse, error) {
+	var acc chatAccumulator
+	err := c.ChatFunc(ctx, req, func(chunk ChatResponse) error {
+		acc.add(chunk)
+		if onChunk != nil && chunk.Message.Content != "" {
+			onChunk(chunk.Message.Content)
+		}
+		return nil
+	})
+	return acc.result(), err
+}
+
+// generateAccumulator assembles streamed generate chunks into one response
+type generateAccumulator struct {
+	resp     GenerateResponse
+	text     strings.Builder
+	thinking strings.Builder
+}
+
+// add records a chunk. Metadata is taken from the latest chunk, so it ends
+// up from the done frame.
+func (a *generateAccumulator) add(chunk GenerateResponse) {
+	a.text.WriteString(chunk.Response)
+	a.thinking.WriteString(chunk.Thinking)
+	a.resp = chunk
+}
+
+// result returns the assembled response, marked partial when the stream
+// ended early
+func (a *generateAccumulator) result() *GenerateResponse {
+	resp := a.resp
+	resp.Response = a.text.String()
+	resp.Thinking = a.thinking.String()
+	resp.Partial = !resp.Done
+	return &resp
+}
+
+// chatAccumulator assembles streamed chat chunks into one response
+type chatAccumulator struct {
+	resp      ChatResponse
+	role      string
+	content   strings.Builder
+	thinking  strings.Builder
+	toolCalls []toolCallBuilder
+	images    []Image
+}
+
+// toolCallBuilder collects the fragments of a streamed tool call
+type toolCallBuilder struct {
+	call ToolCall
+	// args buffers arguments streamed as pieces of a JSON string
+	args     strings.Builder
+	fragment bool
+}
+
+// add records a chunk. Metadata is taken from the latest chunk, so it ends
+// up from the done frame.
+func (a *chatAccumulator) add(chunk ChatResponse) {
+	if a.role == "" {
+		a.role = chunk.Message.Role
+	}
+	a.content.WriteString(chunk.Message.Content)
+	a.thinking.WriteString(chunk.Message.Thinking)
+	for _, call := range chunk.Message.ToolCalls {
+		a.addToolCall(call)
+	}
+	a.images = append(a.images, chunk.Message.Images...)
+	a.resp = chunk
+}
+
+// addToolCall merges a streamed tool call into the calls so far. A call
+// continues an earlier one when it has the same ID, or when it has no name
+// and the same index. Its arguments are appended when they are a string
+// fragment and replace the earlier ones when they are a complete value.
+func (a *chatAccumulator) addToolCall(call ToolCall) {
+	b := a.findToolCall(call)
+	if b == nil {
+		a.toolCalls = append(a.toolCalls, toolCallBuilder{call: call})
+		b = &a.toolCalls[len(a.toolCalls)-1]
+		b.call.Function.Arguments = nil
+	} else {
+		if b.call.ID == "" {
+			b.call.ID = call.ID
+		}
+		if b.call.Type == "" {
+			b.call.Type = call.Type
+		}
+		if b.call.Function.Name == "" {
+			b.call.Function.Name = call.Function.Name
+		}
+	}
+
+	args := call.Function.Arguments
+	var fragment string
+	switch {
+	case len(args) == 0 || string(args) == "null":
+	case json.Unmarshal(args, &fragment) == nil:
+		b.fragment = true
+		b.args.WriteString(fragment)
+	default:
+		b.fragment = false
+		b.args.Reset()
+		b.call.Function.Arguments = append(json.RawMessage(nil), args...)
+	}
+}
+
+// findToolCall returns the earlier call a streamed call continues, if any
+func (a *chatAccumulator) findToolCall(call ToolCall) *toolCallBuilder {
+	for i := len(a.toolCalls) - 1; i >= 0; i-- {
+		b := &a.toolCalls[i]
+		if call.ID != "" {
+			if b.call.ID == call.ID {
+				return b
+			}
+			continue
+		}
+		if call.Function.Name == "" && b.call.Function.Index == call.Function.Index {
+			return b
+		}
+	}
+	return nil
+}
+
+// result returns the assembled response, marked partial when the stream
+// ended early
+func (a *chatAccumulator) result() *ChatResponse {
+	resp := a.resp
+	resp.Message = Message{
+		Role:     a.role,
+		Content:  a.content.String(),
+		Thinking: a.thinking.String(),
+		Images:   a.images,
+	}
+	for _, b := range a.toolCalls {
+		call := b.call
+		if b.fragment {
+			call.Function.Arguments = assembledArguments(b.args.String())
+		}
+		resp.Message.ToolCalls = append(resp.Message.ToolCalls, call)
+	}
+	resp.Partial = !resp.Done
+	return &resp
+}
+
+// assembledArguments returns the arguments collected from string fragments
+// as raw JSON, or as a JSON string when the model produced invalid JSON, so
+// that the call can still be encoded and inspected
+func assembledArguments(args string) json.RawMessage {
+	if json.Valid([]byte(args)) {
+		return json.RawMessage(args)
+	}
+	b, _ := json.Marshal(args)
+	return b
+}
+
+// merge folds a frame that arrived after the done frame into it: text is
+// appended and non-zero metadata replaces the done frame's
+func (r *GenerateResponse) merge(extra GenerateResponse) {
+	r.Response += extra.Response
+	r.Thinking += extra.Thinking
+	if extra.DoneReason != "" {
+		r.DoneReason = extra.DoneReason
+	}
+	if len(extra.Context) > 0 {
+		r.Context = extra.Context
+	}
+	mergeMetrics(&r.TotalDuration, extra.TotalDuration)
+	mergeMetrics(&r.LoadDuration, extra.LoadDuration)
+	mergeMetrics(&r.PromptEvalCount, extra.PromptEvalCount)
+	mergeMetrics(&r.PromptEvalDuration, extra.PromptEvalDuration)
+	mergeMetrics(&r.EvalCount, extra.EvalCount)
+	mergeMetrics(&r.EvalDuration, extra.EvalDuration)
+}
+
+// merge folds a frame that arrived after the done frame into it: message
+// parts are appended and non-zero metadata replaces the done frame's
+func (r *ChatResponse) merge(extra ChatResponse) {
+	r.Message.Content += extra.Message.Content
+	r.Message.Thinking += extra.Message.Thinking
+	r.Message.ToolCalls = append(r.Message.ToolCalls, extra.Message.ToolCalls...)
+	if extra.DoneReason != "" {
+		r.DoneReason = extra.DoneReason
+	}
+	mergeMetrics(&r.TotalDuration, extra.TotalDuration)
+	mergeMetrics(&r.LoadDuration, extra.LoadDuration)
+	mergeMetrics(&r.PromptEvalCount, extra.PromptEvalCount)
+	mergeMetrics(&r.PromptEvalDuration, extra.PromptEvalDuration)
+	mergeMetrics(&r.EvalCount, extra.EvalCount)
+	mergeMetrics(&r.EvalDuration, extra.EvalDuration)
+}
+
+// mergeMetrics replaces a metric with a later non-zero value
+func mergeMetrics[T int | int64](dst *T, v T) {
+	if v != 0 {
+		*dst = v
+	}
+}
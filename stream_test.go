@@ -0,0 +1,690 @@
+// stream_test.go
+package ollamago
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestGenerateStreamFinalFrame(t *testing.T) {
+	tests := []struct {
+		name     string
+		frames   []string
+		stall    bool
+		opts     []Option
+		wantCtx  []int
+		wantEval int
+	}{
+		{
+			name: "done frame",
+			frames: []string{
+				`{"response":"hi"}`,
+				`{"done":true,"context":[1,2],"eval_count":3}`,
+			},
+			wantCtx:  []int{1, 2},
+			wantEval: 3,
+		},
+		{
+			name: "metrics after done",
+			frames: []string{
+				`{"response":"hi"}`,
+				`{"done":true}`,
+				`{"context":[4,5],"eval_count":7}`,
+			},
+			wantCtx:  []int{4, 5},
+			wantEval: 7,
+		},
+		{
+			name: "stall after done",
+			frames: []string{
+				`{"response":"hi"}`,
+				`{"done":true,"context":[1,2],"eval_count":3}`,
+			},
+			stall:    true,
+			opts:     []Option{WithStreamTimeouts(0, 50*time.Millisecond)},
+			wantCtx:  []int{1, 2},
+			wantEval: 3,
+		},
+		{
+			name: "held open after done",
+			frames: []string{
+				`{"response":"hi"}`,
+				`{"done":true,"context":[1,2],"eval_count":3}`,
+			},
+			stall:    true,
+			opts:     []Option{WithTrailingFrameWait(50 * time.Millisecond)},
+			wantCtx:  []int{1, 2},
+			wantEval: 3,
+		},
+		{
+			name: "done delivered immediately",
+			frames: []string{
+				`{"response":"hi"}`,
+				`{"done":true,"context":[1,2],"eval_count":3}`,
+				`{"context":[4,5],"eval_count":7}`,
+			},
+			stall:    true,
+			opts:     []Option{WithTrailingFrameWait(0)},
+			wantCtx:  []int{1, 2},
+			wantEval: 3,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.Header().Set("Content-Type", "application/x-ndjson")
+				for _, frame := range tt.frames {
+					fmt.Fprintln(w, frame)
+				}
+				w.(http.Flusher).Flush()
+				if tt.stall {
+					<-r.Context().Done()
+				}
+			}))
+			defer srv.Close()
+
+			c := NewClient(append([]Option{WithBaseURL(srv.URL)}, tt.opts...)...)
+			chunks, errs := c.GenerateStream(context.Background(), GenerateRequest{Model: "m", Prompt: "hi"})
+			var last GenerateResponse
+			n := 0
+			for chunk := range chunks {
+				last = chunk
+				n++
+			}
+			if err := <-errs; err != nil {
+				t.Fatalf("stream error: %v", err)
+			}
+			if n != 2 {
+				t.Errorf("received %d chunks, want 2", n)
+			}
+			if !last.Done {
+				t.Error("last chunk is not done")
+			}
+			if !slices.Equal(last.Context, tt.wantCtx) {
+				t.Errorf("context = %v, want %v", last.Context, tt.wantCtx)
+			}
+			if last.EvalCount != tt.wantEval {
+				t.Errorf("eval count = %d, want %d", last.EvalCount, tt.wantEval)
+			}
+		})
+	}
+}
+
+func TestGenerateStreamCancelledAfterDone(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/x-ndjson")
+		fmt.Fprintln(w, `{"response":"hi"}`)
+		fmt.Fprintln(w, `{"done":true,"context":[1,2]}`)
+		w.(http.Flusher).Flush()
+		<-r.Context().Done()
+	}))
+	defer srv.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	c := NewClient(WithBaseURL(srv.URL), WithTrailingFrameWait(time.Minute))
+	chunks, errs := c.GenerateStream(ctx, GenerateRequest{Model: "m", Prompt: "hi"})
+	<-chunks
+	// Let the stream read the done frame before cancelling
+	time.Sleep(20 * time.Millisecond)
+	cancel()
+	for chunk := range chunks {
+		t.Errorf("unexpected chunk after cancel: %+v", chunk)
+	}
+	if err := <-errs; !errors.Is(err, context.Canceled) {
+		t.Errorf("err = %v, want context.Canceled", err)
+	}
+}
+
+func TestStreamTimeouts(t *testing.T) {
+	tests := []struct {
+		name    string
+		handler func(w http.ResponseWriter, release <-chan struct{})
+		want    error
+	}{
+		{
+			name: "first byte",
+			handler: func(w http.ResponseWriter, release <-chan struct{}) {
+				<-release
+			},
+			want: ErrFirstByteTimeout,
+		},
+		{
+			name: "stalled",
+			handler: func(w http.ResponseWriter, release <-chan struct{}) {
+				w.Header().Set("Content-Type", "application/x-ndjson")
+				fmt.Fprintln(w, `{"response":"hi"}`)
+				w.(http.Flusher).Flush()
+				<-release
+			},
+			want: ErrStreamStalled,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// release unblocks the handler so the server can close
+			release := make(chan struct{})
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				tt.handler(w, release)
+			}))
+			defer srv.Close()
+			defer close(release)
+
+			c := NewClient(WithBaseURL(srv.URL), WithStreamTimeouts(50*time.Millisecond, 50*time.Millisecond))
+			chunks, errs := c.GenerateStream(context.Background(), GenerateRequest{Model: "m", Prompt: "hi"})
+			for range chunks {
+			}
+			if err := <-errs; !errors.Is(err, tt.want) {
+				t.Errorf("err = %v, want %v", err, tt.want)
+			}
+
+			chatChunks, chatErrs := c.ChatStream(context.Background(), ChatRequest{Model: "m"})
+			for range chatChunks {
+			}
+			if err := <-chatErrs; !errors.Is(err, tt.want) {
+				t.Errorf("chat err = %v, want %v", err, tt.want)
+			}
+		})
+	}
+}
+
+// ndjsonServer streams frames, one per line, in answer to every request
+func ndjsonServer(t *testing.T, frames ...string) *httptest.Server {
+	t.Helper()
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/x-ndjson")
+		for _, frame := range frames {
+			fmt.Fprintln(w, frame)
+		}
+	}))
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+func TestStreamAssertions(t *testing.T) {
+	tests := []struct {
+		name    string
+		frames  []string
+		wantErr bool
+	}{
+		{
+			name:   "valid",
+			frames: []string{`{"response":"a","eval_count":1}`, `{"done":true,"eval_count":2}`},
+		},
+		{
+			name:    "frame after done",
+			frames:  []string{`{"response":"a"}`, `{"done":true}`, `{"response":"b"}`},
+			wantErr: true,
+		},
+		{
+			name:    "no done",
+			frames:  []string{`{"response":"a"}`, `{"response":"b"}`},
+			wantErr: true,
+		},
+		{
+			name:    "eval count decreases",
+			frames:  []string{`{"response":"a","eval_count":5}`, `{"done":true,"eval_count":3}`},
+			wantErr: true,
+		},
+		{
+			name:    "prompt eval count decreases",
+			frames:  []string{`{"response":"a","prompt_eval_count":5}`, `{"done":true,"prompt_eval_count":3}`},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			srv := ndjsonServer(t, tt.frames...)
+			c := NewClient(WithBaseURL(srv.URL), WithStreamAssertions(true))
+
+			chunks, errs := c.GenerateStream(context.Background(), GenerateRequest{Model: "m", Prompt: "hi"})
+			for range chunks {
+			}
+			err := <-errs
+			if errors.Is(err, ErrStreamViolation) != tt.wantErr {
+				t.Errorf("err = %v, want violation: %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestGenerateMultiStream(t *testing.T) {
+	tests := []struct {
+		name    string
+		prompts []string
+		want    []string
+		wantErr []bool
+	}{
+		{
+			name:    "two streams",
+			prompts: []string{"a", "b"},
+			want:    []string{"a0 a1 a2 ", "b0 b1 b2 "},
+			wantErr: []bool{false, false},
+		},
+		{
+			name:    "one failure",
+			prompts: []string{"a", "fail"},
+			want:    []string{"a0 a1 a2 ", ""},
+			wantErr: []bool{false, true},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// Each handler waits until every request is in flight, so the
+			// streams are known to run concurrently
+			var arrived sync.WaitGroup
+			arrived.Add(len(tt.prompts))
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				var req GenerateRequest
+				if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+					t.Errorf("decoding request: %v", err)
+				}
+				arrived.Done()
+				arrived.Wait()
+
+				if req.Prompt == "fail" {
+					w.Header().Set("Content-Type", "application/json")
+					w.WriteHeader(http.StatusNotFound)
+					fmt.Fprint(w, `{"error":"model not found"}`)
+					return
+				}
+				w.Header().Set("Content-Type", "application/x-ndjson")
+				for i := 0; i < 3; i++ {
+					fmt.Fprintf(w, `{"response":"%s%d "}`+"\n", req.Prompt, i)
+					w.(http.Flusher).Flush()
+				}
+				fmt.Fprintln(w, `{"done":true}`)
+			}))
+			defer srv.Close()
+
+			c := NewClient(WithBaseURL(srv.URL))
+			reqs := make([]GenerateRequest, len(tt.prompts))
+			for i, p := range tt.prompts {
+				reqs[i] = GenerateRequest{Model: "m", Prompt: p}
+			}
+
+			ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+			defer cancel()
+			text := make([]string, len(reqs))
+			failed := make([]bool, len(reqs))
+			done := make([]bool, len(reqs))
+			for chunk := range c.GenerateMultiStream(ctx, reqs) {
+				if chunk.Index < 0 || chunk.Index >= len(reqs) {
+					t.Fatalf("chunk index %d out of range", chunk.Index)
+				}
+				if chunk.Err != nil {
+					failed[chunk.Index] = true
+					continue
+				}
+				text[chunk.Index] += chunk.Response.Response
+				done[chunk.Index] = done[chunk.Index] || chunk.Response.Done
+			}
+
+			if ctx.Err() != nil {
+				t.Fatal("channel was not closed after both streams finished")
+			}
+			if !slices.Equal(text, tt.want) {
+				t.Errorf("text = %q, want %q", text, tt.want)
+			}
+			if !slices.Equal(failed, tt.wantErr) {
+				t.Errorf("failed = %v, want %v", failed, tt.wantErr)
+			}
+			for i := range done {
+				if done[i] == tt.wantErr[i] {
+					t.Errorf("stream %d: done = %v, want %v", i, done[i], !tt.wantErr[i])
+				}
+			}
+		})
+	}
+}
+
+func TestChatStreamSplit(t *testing.T) {
+	srv := ndjsonServer(t,
+		`{"message":{"role":"assistant","thinking":"hmm "}}`,
+		`{"message":{"role":"assistant","content":"The "}}`,
+		`{"message":{"role":"assistant","thinking":"right"}}`,
+		`{"message":{"role":"assistant","content":"answer"}}`,
+		`{"message":{"role":"assistant"},"done":true}`,
+	)
+	c := NewClient(WithBaseURL(srv.URL))
+
+	thinking, content, errs := c.ChatStreamSplit(context.Background(), ChatRequest{Model: "m"})
+	var gotThinking, gotContent string
+	thinkingOpen, contentOpen := true, true
+	for {
+		select {
+		case delta, ok := <-thinking:
+			if !ok {
+				thinkingOpen, thinking = false, nil
+				continue
+			}
+			gotThinking += delta
+		case delta, ok := <-content:
+			if !ok {
+				contentOpen, content = false, nil
+				continue
+			}
+			gotContent += delta
+		case err, ok := <-errs:
+			if ok {
+				t.Fatalf("err = %v", err)
+			}
+			// A channel closed before errs is ready to receive from
+			if thinkingOpen {
+				select {
+				case <-thinking:
+				default:
+					t.Error("thinking still open after errs closed")
+				}
+			}
+			if contentOpen {
+				select {
+				case <-content:
+				default:
+					t.Error("content still open after errs closed")
+				}
+			}
+			if gotThinking != "hmm right" {
+				t.Errorf("thinking = %q, want %q", gotThinking, "hmm right")
+			}
+			if gotContent != "The answer" {
+				t.Errorf("content = %q, want %q", gotContent, "The answer")
+			}
+			return
+		case <-time.After(5 * time.Second):
+			t.Fatal("stream did not finish")
+		}
+	}
+}
+
+func TestChatAccumulateToolCalls(t *testing.T) {
+	type call struct{ id, name, args string }
+	tests := []struct {
+		name   string
+		frames []string
+		want   []call
+	}{
+		{
+			name: "fragments by id",
+			frames: []string{
+				`{"message":{"role":"assistant","tool_calls":[{"id":"c1","function":{"name":"get_weather","arguments":"{\"ci"}}]}}`,
+				`{"message":{"role":"assistant","tool_calls":[{"id":"c1","function":{"arguments":"ty\":\"Paris\"}"}}]}}`,
+				`{"message":{"role":"assistant"},"done":true}`,
+			},
+			want: []call{{"c1", "get_weather", `{"city":"Paris"}`}},
+		},
+		{
+			name: "fragments by index",
+			frames: []string{
+				`{"message":{"role":"assistant","tool_calls":[{"function":{"index":0,"name":"get_weather","arguments":"{\"city\":"}}]}}`,
+				`{"message":{"role":"assistant","tool_calls":[{"function":{"index":0,"arguments":"\"Rome\"}"}}]}}`,
+				`{"message":{"role":"assistant"},"done":true}`,
+			},
+			want: []call{{"", "get_weather", `{"city":"Rome"}`}},
+		},
+		{
+			name: "two calls",
+			frames: []string{
+				`{"message":{"role":"assistant","tool_calls":[{"id":"c1","function":{"name":"get_weather","arguments":{"city":"Paris"}}}]}}`,
+				`{"message":{"role":"assistant","tool_calls":[{"id":"c2","function":{"index":1,"name":"get_time","arguments":{"zone":"CET"}}}]}}`,
+				`{"message":{"role":"assistant"},"done":true}`,
+			},
+			want: []call{
+				{"c1", "get_weather", `{"city":"Paris"}`},
+				{"c2", "get_time", `{"zone":"CET"}`},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			srv := ndjsonServer(t, tt.frames...)
+			c := NewClient(WithBaseURL(srv.URL))
+
+			resp, err := c.ChatAccumulate(context.Background(), ChatRequest{Model: "m"}, nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			var got []call
+			for _, tc := range resp.Message.ToolCalls {
+				got = append(got, call{tc.ID, tc.Function.Name, string(tc.Function.Arguments)})
+			}
+			if !slices.Equal(got, tt.want) {
+				t.Errorf("tool calls = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestStreamServerSentEvents(t *testing.T) {
+	tests := []struct {
+		name        string
+		contentType string
+		body        string
+	}{
+		{
+			name:        "sse",
+			contentType: "text/event-stream",
+			body: ": keep-alive\n" +
+				"event: message\n" +
+				"id: 1\n" +
+				`data: {"response":"Hello"}` + "\n\n" +
+				"event: message\n" +
+				`data:{"response":", world"}` + "\n\n" +
+				`data: {"done":true,"eval_count":2}` + "\n\n" +
+				"data: [DONE]\n\n",
+		},
+		{
+			name:        "ndjson",
+			contentType: "application/x-ndjson",
+			body:        `{"response":"Hello"}` + "\n" + `{"response":", world"}` + "\n" + `{"done":true,"eval_count":2}` + "\n",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.Header().Set("Content-Type", tt.contentType)
+				fmt.Fprint(w, tt.body)
+			}))
+			defer srv.Close()
+			c := NewClient(WithBaseURL(srv.URL))
+
+			resp, err := c.GenerateAccumulate(context.Background(), GenerateRequest{Model: "m", Prompt: "hi"}, nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if resp.Response != "Hello, world" || !resp.Done || resp.EvalCount != 2 {
+				t.Errorf("response = %q, done %v, eval_count %d", resp.Response, resp.Done, resp.EvalCount)
+			}
+		})
+	}
+}
+
+func TestHasContext(t *testing.T) {
+	// Like Ollama, the server only returns a context on the final frame of
+	// a templated request
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req GenerateRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			t.Errorf("decoding request: %v", err)
+		}
+		final := `{"response":"","done":true,"context":[1,2,3]}`
+		if req.Raw {
+			final = `{"response":"","done":true}`
+		}
+		if !req.Stream {
+			w.Header().Set("Content-Type", "application/json")
+			fmt.Fprint(w, strings.Replace(final, `"response":""`, `"response":"hi"`, 1))
+			return
+		}
+		w.Header().Set("Content-Type", "application/x-ndjson")
+		fmt.Fprintln(w, `{"response":"h"}`)
+		fmt.Fprintln(w, `{"response":"i"}`)
+		fmt.Fprintln(w, final)
+	}))
+	defer srv.Close()
+	c := NewClient(WithBaseURL(srv.URL))
+	ctx := context.Background()
+
+	for _, raw := range []bool{false, true} {
+		t.Run(fmt.Sprintf("raw=%v", raw), func(t *testing.T) {
+			req := GenerateRequest{Model: "m", Prompt: "hi", Raw: raw}
+
+			resp, err := c.Generate(ctx, req)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if resp.HasContext() == raw {
+				t.Errorf("Generate: HasContext = %v, want %v", resp.HasContext(), !raw)
+			}
+
+			chunks, errs := c.GenerateStream(ctx, req)
+			var got []bool
+			for chunk := range chunks {
+				got = append(got, chunk.HasContext())
+			}
+			if err := <-errs; err != nil {
+				t.Fatal(err)
+			}
+			want := []bool{false, false, !raw}
+			if !slices.Equal(got, want) {
+				t.Errorf("stream: HasContext per chunk = %v, want %v", got, want)
+			}
+		})
+	}
+}
+
+func TestStartGenerate(t *testing.T) {
+	release := make(chan struct{})
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/x-ndjson")
+		fmt.Fprintln(w, `{"response":"Hello"}`)
+		fmt.Fprintln(w, `{"response":", wor"}`)
+		w.(http.Flusher).Flush()
+		select {
+		case <-release:
+			fmt.Fprintln(w, `{"response":"ld","done":true}`)
+		case <-r.Context().Done():
+		}
+	}))
+	defer srv.Close()
+	c := NewClient(WithBaseURL(srv.URL))
+	req := GenerateRequest{Model: "m", Prompt: "hi"}
+
+	t.Run("stopped", func(t *testing.T) {
+		g := c.StartGenerate(context.Background(), req)
+		defer g.Stop()
+		deadline := time.Now().Add(5 * time.Second)
+		for g.Snapshot().Response != "Hello, wor" {
+			if time.Now().After(deadline) {
+				t.Fatalf("snapshot = %q", g.Snapshot().Response)
+			}
+			time.Sleep(5 * time.Millisecond)
+		}
+		if snap := g.Snapshot(); !snap.Partial {
+			t.Error("running snapshot is not partial")
+		}
+
+		resp := g.Stop()
+		if resp.Response != "Hello, wor" || !resp.Partial {
+			t.Errorf("Stop = %q, partial %v", resp.Response, resp.Partial)
+		}
+		resp, err := g.Wait()
+		if !errors.Is(err, context.Canceled) {
+			t.Errorf("Wait err = %v, want context.Canceled", err)
+		}
+		if resp.Response != "Hello, wor" || !resp.Partial {
+			t.Errorf("Wait = %q, partial %v", resp.Response, resp.Partial)
+		}
+	})
+
+	t.Run("complete", func(t *testing.T) {
+		close(release)
+		g := c.StartGenerate(context.Background(), req)
+		resp, err := g.Wait()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if resp.Response != "Hello, world" || resp.Partial {
+			t.Errorf("Wait = %q, partial %v", resp.Response, resp.Partial)
+		}
+	})
+}
+
+func TestTokenMeter(t *testing.T) {
+	release := make(chan struct{})
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/x-ndjson")
+		for i := 0; i < 3; i++ {
+			fmt.Fprintln(w, `{"response":"a","message":{"role":"assistant","content":"a"}}`)
+		}
+		w.(http.Flusher).Flush()
+		select {
+		case <-release:
+		case <-r.Context().Done():
+			return
+		}
+		fmt.Fprintln(w, `{"message":{"role":"assistant"},"done":true}`)
+	}))
+	defer srv.Close()
+	finish := sync.OnceFunc(func() { close(release) })
+	defer finish()
+
+	// One meter shared by two clients, each running a stream
+	meter := NewTokenMeter(time.Minute)
+	gen := NewClient(WithBaseURL(srv.URL), WithTokenMeter(meter))
+	chat := NewClient(WithBaseURL(srv.URL), WithTokenMeter(meter))
+	ctx := context.Background()
+
+	genChunks, genErrs := gen.GenerateStream(ctx, GenerateRequest{Model: "m", Prompt: "hi"})
+	chatChunks, chatErrs := chat.ChatStream(ctx, ChatRequest{Model: "m"})
+	var wg sync.WaitGroup
+	wg.Add(2)
+	go func() {
+		defer wg.Done()
+		for range genChunks {
+		}
+	}()
+	go func() {
+		defer wg.Done()
+		for range chatChunks {
+		}
+	}()
+
+	deadline := time.Now().Add(5 * time.Second)
+	for meter.Reading().TotalTokens < 6 {
+		if time.Now().After(deadline) {
+			t.Fatalf("reading = %+v, want 6 tokens", meter.Reading())
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	reading := meter.Reading()
+	if reading.ActiveStreams != 2 || reading.TotalTokens != 6 || reading.TokensPerSecond != 6.0/60 {
+		t.Errorf("reading during streams = %+v, want 2 streams at 0.1 tokens/s", reading)
+	}
+
+	finish()
+	wg.Wait()
+	if err := <-genErrs; err != nil {
+		t.Fatal(err)
+	}
+	if err := <-chatErrs; err != nil {
+		t.Fatal(err)
+	}
+	if reading := meter.Reading(); reading.ActiveStreams != 0 || reading.TotalTokens != 6 {
+		t.Errorf("reading after streams = %+v, want no active streams and 6 tokens", reading)
+	}
+
+	// Tokens leave the rate once they fall out of the window
+	if reading := meter.readingAt(time.Now().Add(time.Minute + time.Second)); reading.TokensPerSecond != 0 || reading.TotalTokens != 6 {
+		t.Errorf("reading after the window = %+v", reading)
+	}
+}
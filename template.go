@@ -0,0 +1,177 @@
+// template.go
+package ollamago
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"text/template"
+	"time"
+)
+
+// chatTemplate is a parsed prompt template, or the error that prevented it
+// from being parsed, with the model's default system prompt
+type chatTemplate struct {
+	tmpl   *template.Template
+	err    error
+	system string
+}
+
+// templateFuncs mirrors the helper functions available to Ollama templates
+var templateFuncs = template.FuncMap{
+	"json": func(v interface{}) string {
+		b, _ := json.Marshal(v)
+		return string(b)
+	},
+	"currentDate": func() string {
+		return time.Now().Format("2006-01-02")
+	},
+}
+
+// templateData is the data passed to a prompt template
+type templateData struct {
+	System   string
+	Prompt   string
+	Suffix   string
+	Response string
+	Messages []Message
+	Tools    []Tool
+}
+
+// parseChatTemplate parses an Ollama prompt template
+func parseChatTemplate(model, text string) *chatTemplate {
+	tmpl, err := template.New(model).Funcs(templateFuncs).Parse(text)
+	if err != nil {
+		return &chatTemplate{err: fmt.Errorf("parsing template for %s: %w", model, err)}
+	}
+	return &chatTemplate{tmpl: tmpl}
+}
+
+// WithChatTemplate overrides the prompt template used when formatting
+// conversations for the given model. Parse errors are reported by the first
+// call that needs the template. With an override the model's default system
+// prompt is not fetched, so conversations without a system message render
+// without one.
+func WithChatTemplate(model, tmpl string) Option {
+	return func(c *Client) {
+		c.templatesMu.Lock()
+		defer c.templatesMu.Unlock()
+		c.templates[model] = parseChatTemplate(model, tmpl)
+	}
+}
+
+// modelTemplate returns the prompt template and default system prompt of a
+// model, fetching them with ShowModel and caching them when no override was
+// configured. A template that failed to parse is returned with its error
+// set, so callers that only need the system prompt can still use it.
+func (c *Client) modelTemplate(ctx context.Context, model string) (*chatTemplate, error) {
+	c.templatesMu.Lock()
+	t, ok := c.templates[model]
+	c.templatesMu.Unlock()
+	if ok {
+		return t, nil
+	}
+
+	info, err := c.ShowModel(ctx, ShowModelRequest{Name: model})
+	if err != nil {
+		return nil, fmt.Errorf("fetching template: %w", err)
+	}
+
+	t = parseChatTemplate(model, info.Template)
+	t.system = info.System
+	c.templatesMu.Lock()
+	c.templates[model] = t
+	c.templatesMu.Unlock()
+	return t, nil
+}
+
+// RenderChat formats a chat request into the raw prompt the model's template
+// produces, suitable for a GenerateRequest with Raw set. As on the server,
+// the model's default system prompt is used when the conversation has no
+// system message.
+func (c *Client) RenderChat(ctx context.Context, req ChatRequest) (string, error) {
+	if req.Model == "" {
+		return "", &RequestError{Message: "model is required"}
+	}
+
+	t, err := c.modelTemplate(ctx, req.Model)
+	if err != nil {
+		return "", err
+	}
+	if t.err != nil {
+		return "", t.err
+	}
+
+	data := templateData{
+		Messages: req.Messages,
+		Tools:    req.Tools,
+	}
+	var system []string
+	for _, msg := range req.Messages {
+		switch msg.Role {
+		case "system":
+			system = append(system, msg.Content)
+		case "user":
+			data.Prompt = msg.Content
+		}
+	}
+	// Like the server, fall back to the model's system prompt when the
+	// conversation has none
+	if len(system) == 0 && t.system != "" {
+		system = append(system, t.system)
+		data.Messages = append([]Message{{Role: "system", Content: t.system}}, req.Messages...)
+	}
+	data.System = strings.Join(system, "\n\n")
+
+	return executeTemplate(t.tmpl, req.Model, data)
+}
+
+// renderGenerate reconstructs the prompt the server builds for a generate
+// request from the request's template or the model's, and the request's
+// system prompt or the model's
+func (c *Client) renderGenerate(ctx context.Context, req GenerateRequest) (string, error) {
+	if req.Raw {
+		return req.Prompt, nil
+	}
+
+	t, err := c.modelTemplate(ctx, req.Model)
+	if err != nil {
+		return "", err
+	}
+	if req.Template != "" {
+		override := parseChatTemplate(req.Model, req.Template)
+		override.system = t.system
+		t = override
+	}
+	if t.err != nil {
+		return "", t.err
+	}
+
+	// Like the server, fall back to the model's system prompt when the
+	// request has none
+	system := req.System
+	if system == "" {
+		system = t.system
+	}
+	data := templateData{
+		System: system,
+		Prompt: req.Prompt,
+		Suffix: req.Suffix,
+	}
+	if system != "" {
+		data.Messages = append(data.Messages, Message{Role: "system", Content: system})
+	}
+	data.Messages = append(data.Messages, Message{Role: "user", Content: req.Prompt, Images: req.Images})
+
+	return executeTemplate(t.tmpl, req.Model, data)
+}
+
+// executeTemplate renders a prompt template
+func executeTemplate(tmpl *template.Template, model string, data templateData) (string, error) {
+	var sb strings.Builder
+	if err := tmpl.Execute(&sb, data); err != nil {
+		return "", fmt.Errorf("executing template for %s: %w", model, err)
+	}
+	return sb.String(), nil
+}
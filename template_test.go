@@ -0,0 +1,121 @@
+// template_test.go
+package ollamago
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// templateServer serves /api/show with a template and system prompt, and
+// answers generate and chat requests
+func templateServer(t *testing.T, tmpl, system string, shows *int) *httptest.Server {
+	t.Helper()
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		switch r.URL.Path {
+		case "/api/show":
+			if shows != nil {
+				*shows++
+			}
+			fmt.Fprintf(w, `{"template":%q,"system":%q}`, tmpl, system)
+		default:
+			fmt.Fprint(w, `{"response":"ok","message":{"role":"assistant","content":"ok"},"done":true}`)
+		}
+	}))
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+func TestReturnPrompt(t *testing.T) {
+	srv := templateServer(t, "{{ if .System }}<s>{{ .System }}</s>{{ end }}<u>{{ .Prompt }}</u>", "Be terse.", nil)
+	c := NewClient(WithBaseURL(srv.URL), WithReturnPrompt(true))
+	ctx := context.Background()
+
+	generate := []struct {
+		name string
+		req  GenerateRequest
+		want string
+	}{
+		{"model system", GenerateRequest{Model: "m", Prompt: "hi"}, "<s>Be terse.</s><u>hi</u>"},
+		{"request system", GenerateRequest{Model: "m", Prompt: "hi", System: "Be kind."}, "<s>Be kind.</s><u>hi</u>"},
+		{"request template", GenerateRequest{Model: "m", Prompt: "hi", Template: "{{ .System }}: {{ .Prompt }}"}, "Be terse.: hi"},
+		{"raw", GenerateRequest{Model: "m", Prompt: "<u>hi</u>", Raw: true}, "<u>hi</u>"},
+	}
+	for _, tt := range generate {
+		t.Run("generate/"+tt.name, func(t *testing.T) {
+			resp, err := c.Generate(ctx, tt.req)
+			if err != nil {
+				t.Fatalf("Generate: %v", err)
+			}
+			if resp.Prompt != tt.want {
+				t.Errorf("prompt = %q, want %q", resp.Prompt, tt.want)
+			}
+		})
+	}
+
+	chat := []struct {
+		name     string
+		messages []Message
+		want     string
+	}{
+		{"model system", []Message{{Role: "user", Content: "hi"}}, "<s>Be terse.</s><u>hi</u>"},
+		{"request system", []Message{{Role: "system", Content: "Be kind."}, {Role: "user", Content: "hi"}}, "<s>Be kind.</s><u>hi</u>"},
+	}
+	for _, tt := range chat {
+		t.Run("chat/"+tt.name, func(t *testing.T) {
+			resp, err := c.Chat(ctx, ChatRequest{Model: "m", Messages: tt.messages})
+			if err != nil {
+				t.Fatalf("Chat: %v", err)
+			}
+			if resp.Prompt != tt.want {
+				t.Errorf("prompt = %q, want %q", resp.Prompt, tt.want)
+			}
+		})
+	}
+}
+
+func TestChatTemplate(t *testing.T) {
+	shows := 0
+	srv := templateServer(t, "<u>{{ .Prompt }}</u>", "", &shows)
+	c := NewClient(
+		WithBaseURL(srv.URL),
+		WithChatTemplate("custom", "{{ range .Messages }}{{ .Role }}: {{ .Content }}\n{{ end }}"),
+		WithChatTemplate("broken", "{{ .Prompt "),
+	)
+	ctx := context.Background()
+	msgs := []Message{{Role: "user", Content: "hi"}}
+
+	got, err := c.RenderChat(ctx, ChatRequest{Model: "custom", Messages: msgs})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := "user: hi\n"; got != want {
+		t.Errorf("override = %q, want %q", got, want)
+	}
+	if shows != 0 {
+		t.Errorf("override fetched the model %d times, want 0", shows)
+	}
+
+	// The model's own template is fetched once and cached
+	for i := 0; i < 3; i++ {
+		got, err := c.RenderChat(ctx, ChatRequest{Model: "served", Messages: msgs})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if want := "<u>hi</u>"; got != want {
+			t.Errorf("served = %q, want %q", got, want)
+		}
+	}
+	if shows != 1 {
+		t.Errorf("/api/show called %d times, want 1", shows)
+	}
+
+	// A bad override is reported when it is needed, not by NewClient
+	if _, err := c.RenderChat(ctx, ChatRequest{Model: "broken", Messages: msgs}); err == nil || !strings.Contains(err.Error(), "parsing template for broken") {
+		t.Errorf("err = %v, want the parse error", err)
+	}
+}
@@ -0,0 +1,151 @@
+// tokens.go
+package ollamago
+
+import (
+	"context"
+	"net/http"
+	"strings"
+	"unicode/utf8"
+)
+
+// TokenCounter counts the tokens in a text. Helpers that budget context use
+// the client's counter, set with WithTokenCounter.
+type TokenCounter interface {
+	Count(text string) int
+}
+
+// HeuristicCounter estimates token counts without a tokenizer, assuming
+// about four characters per token and at least one token per word
+type HeuristicCounter struct{}
+
+// Count estimates the number of tokens in text
+func (HeuristicCounter) Count(text string) int {
+	n := (utf8.RuneCountInString(text) + 3) / 4
+	if words := len(strings.Fields(text)); words > n {
+		n = words
+	}
+	return n
+}
+
+// ServerTokenCounter counts tokens with a model's own tokenizer by asking
+// the server to evaluate the text without generating anything. Each count is
+// a request, so it is accurate but slow.
+type ServerTokenCounter struct {
+	client *Client
+	model  string
+}
+
+// NewServerTokenCounter returns a counter using the tokenizer of model
+func NewServerTokenCounter(client *Client, model string) *ServerTokenCounter {
+	return &ServerTokenCounter{client: client, model: model}
+}
+
+// CountContext returns the number of tokens the model's tokenizer produces
+// for text. The server may reuse a cached prompt prefix and report fewer
+// tokens, so counts of repeated texts can be low.
+func (s *ServerTokenCounter) CountContext(ctx context.Context, text string) (int, error) {
+	// The request bypasses Generate so that client-wide options such as
+	// WithErrorOnEmptyResponse and WithUsageTracker do not apply to it
+	req := GenerateRequest{
+		Model:   s.model,
+		Prompt:  text,
+		Raw:     true,
+		Options: &Options{NumPredict: ptr(0)},
+	}
+	var resp GenerateResponse
+	if err := s.client.request(ctx, http.MethodPost, "/api/generate", req, &resp, false); err != nil {
+		return 0, err
+	}
+	return resp.PromptEvalCount, nil
+}
+
+// Count returns the number of tokens in text, falling back to the heuristic
+// estimate when the server cannot be reached
+func (s *ServerTokenCounter) Count(text string) int {
+	n, err := s.CountContext(context.Background(), text)
+	if err != nil {
+		return HeuristicCounter{}.Count(text)
+	}
+	return n
+}
+
+// WithTokenCounter sets the token counter used by the client's helpers. The
+// default is HeuristicCounter.
+func WithTokenCounter(counter TokenCounter) Option {
+	return func(c *Client) {
+		c.tokenCounter = counter
+	}
+}
+
+// TokenCounter returns the client's token counter
+func (c *Client) TokenCounter() TokenCounter {
+	if c.tokenCounter == nil {
+		return HeuristicCounter{}
+	}
+	return c.tokenCounter
+}
+
+// messageOverhead approximates the tokens a chat template adds around each
+// message for its role markers
+const messageOverhead = 4
+
+// MessageTokens estimates the tokens a message takes in the prompt
+func MessageTokens(counter TokenCounter, msg Message) int {
+	n := messageOverhead + counter.Count(msg.Content)
+	for _, tc := range msg.ToolCalls {
+		n += counter.Count(tc.Function.Name) + counter.Count(string(tc.Function.Arguments))
+	}
+	return n
+}
+
+// TrimMessages drops the oldest messages until the conversation fits in
+// maxTokens by the HeuristicCounter estimate. See TrimMessagesWith.
+func TrimMessages(msgs []Message, maxTokens int, keepSystem bool) []Message {
+	return TrimMessagesWith(HeuristicCounter{}, msgs, maxTokens, keepSystem)
+}
+
+// TrimMessagesWith drops the oldest messages until the conversation fits in
+// maxTokens by counter's estimate. System messages are kept when keepSystem
+// is set, the last message is always kept, and tool results are dropped
+// together with the call that produced them, so a call whose results end
+// the conversation is kept. The result may still exceed the budget when the
+// kept messages alone do. msgs is not modified.
+func TrimMessagesWith(counter TokenCounter, msgs []Message, maxTokens int, keepSystem bool) []Message {
+	total := 0
+	for _, msg := range msgs {
+		total += MessageTokens(counter, msg)
+	}
+
+	dropped := make([]bool, len(msgs))
+	for i := 0; i < len(msgs)-1 && total > maxTokens; i++ {
+		if keepSystem && msgs[i].Role == "system" {
+			continue
+		}
+
+		// Results of dropped tool calls would be orphaned, so a call is
+		// dropped with all of its results, and kept when they run up to the
+		// last message
+		end := i + 1
+		if len(msgs[i].ToolCalls) > 0 {
+			for end < len(msgs) && msgs[end].Role == "tool" {
+				end++
+			}
+			if end == len(msgs) {
+				break
+			}
+		}
+		for ; i < end; i++ {
+			dropped[i] = true
+			total -= MessageTokens(counter, msgs[i])
+		}
+		i--
+	}
+
+	out := make([]Message, 0, len(msgs))
+	for i, msg := range msgs {
+		if !dropped[i] {
+			out = append(out, msg)
+		}
+	}
+	return out
+}
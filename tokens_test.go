@@ -0,0 +1,77 @@
+// tokens_test.go
+package ollamago
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestTrimMessagesKeepsToolGroups(t *testing.T) {
+	long := strings.Repeat("word ", 50)
+	call := Message{Role: "assistant", ToolCalls: []ToolCall{{Function: FunctionCall{Name: "f"}}}}
+
+	tests := []struct {
+		name string
+		msgs []Message
+		want []string
+	}{
+		{
+			name: "drops call with results",
+			msgs: []Message{
+				{Role: "user", Content: long},
+				call,
+				{Role: "tool", Content: long},
+				{Role: "user", Content: "next"},
+			},
+			want: []string{"user"},
+		},
+		{
+			name: "keeps call whose results end the conversation",
+			msgs: []Message{
+				{Role: "user", Content: long},
+				call,
+				{Role: "tool", Content: long},
+				{Role: "tool", Content: "last"},
+			},
+			want: []string{"assistant", "tool", "tool"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			out := TrimMessages(tt.msgs, 20, true)
+			var roles []string
+			for _, msg := range out {
+				roles = append(roles, msg.Role)
+			}
+			if !slices.Equal(roles, tt.want) {
+				t.Errorf("roles = %v, want %v", roles, tt.want)
+			}
+		})
+	}
+}
+
+func TestServerTokenCounterBypassesClientOptions(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		fmt.Fprint(w, `{"response":"","done":true,"prompt_eval_count":42}`)
+	}))
+	defer srv.Close()
+
+	var tracker UsageTracker
+	c := NewClient(WithBaseURL(srv.URL), WithErrorOnEmptyResponse(true), WithUsageTracker(&tracker))
+	n, err := NewServerTokenCounter(c, "m").CountContext(context.Background(), "some text")
+	if err != nil {
+		t.Fatalf("CountContext: %v", err)
+	}
+	if n != 42 {
+		t.Errorf("count = %d, want 42", n)
+	}
+	if usage := tracker.Snapshot(); usage.PromptTokens != 0 {
+		t.Errorf("token count was tracked as usage: %+v", usage)
+	}
+}
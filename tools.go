@@ -0,0 +1,401 @@
+// tools.go
+package ollamago
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ToolHandler runs a tool with the arguments the model supplied and returns
+// the result to send back to the model
+type ToolHandler func(ctx context.Context, args json.RawMessage) (string, error)
+
+// JSONToolHandler runs a tool and returns a structured result. Strings are
+// sent to the model as they are; any other result is marshaled to JSON.
+type JSONToolHandler func(ctx context.Context, args json.RawMessage) (any, error)
+
+// ToolResult is a structured tool result naming the tool that produced it,
+// for models that expect tool results as {"name": ..., "content": ...}
+// objects. Return it from a JSONToolHandler.
+type ToolResult struct {
+	Name    string `json:"name"`
+	Content any    `json:"content"`
+}
+
+// ToolRegistry holds tools the model may call, with their handlers
+type ToolRegistry struct {
+	mu       sync.RWMutex
+	tools    []Tool
+	handlers map[string]ToolHandler
+}
+
+// NewToolRegistry creates an empty tool registry
+func NewToolRegistry() *ToolRegistry {
+	return &ToolRegistry{handlers: make(map[string]ToolHandler)}
+}
+
+// Register adds a function tool. Registering a name again replaces the
+// previous definition and handler.
+func (r *ToolRegistry) Register(fn Function, handler ToolHandler) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	tool := Tool{Type: "function", Function: fn}
+	if _, ok := r.handlers[fn.Name]; ok {
+		for i := range r.tools {
+			if r.tools[i].Function.Name == fn.Name {
+				r.tools[i] = tool
+			}
+		}
+	} else {
+		r.tools = append(r.tools, tool)
+	}
+	r.handlers[fn.Name] = handler
+}
+
+// RegisterJSON adds a function tool whose handler returns a structured
+// result, which is marshaled to JSON for the tool message. A result that
+// cannot be marshaled fails the call like a handler error.
+func (r *ToolRegistry) RegisterJSON(fn Function, handler JSONToolHandler) {
+	r.Register(fn, func(ctx context.Context, args json.RawMessage) (string, error) {
+		out, err := handler(ctx, args)
+		if err != nil {
+			return "", err
+		}
+		if s, ok := out.(string); ok {
+			return s, nil
+		}
+		b, err := json.Marshal(out)
+		if err != nil {
+			return "", fmt.Errorf("encoding result of %s: %w", fn.Name, err)
+		}
+		return string(b), nil
+	})
+}
+
+// Tools returns the definitions of the registered tools
+func (r *ToolRegistry) Tools() []Tool {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return append([]Tool(nil), r.tools...)
+}
+
+// Dispatch checks a tool call against the registered tool with
+// ValidateToolCall and runs its handler. Calls to unknown tools and calls
+// with invalid arguments return an error wrapping ErrInvalidToolCall without
+// running the handler.
+func (r *ToolRegistry) Dispatch(ctx context.Context, call ToolCall) (string, error) {
+	handler, err := r.lookup(call)
+	if err != nil {
+		return "", err
+	}
+	return handler(ctx, call.Function.Arguments)
+}
+
+// lookup returns the handler for a tool call after validating the call
+func (r *ToolRegistry) lookup(call ToolCall) (ToolHandler, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	handler, ok := r.handlers[call.Function.Name]
+	if !ok {
+		return nil, fmt.Errorf("%w: unknown tool %q", ErrInvalidToolCall, call.Function.Name)
+	}
+	for _, tool := range r.tools {
+		if tool.Function.Name == call.Function.Name {
+			if err := ValidateToolCall(call, tool); err != nil {
+				return nil, err
+			}
+		}
+	}
+	return handler, nil
+}
+
+// DefaultMaxToolIterations is the default number of model calls
+// ChatWithTools makes before giving up
+const DefaultMaxToolIterations = 10
+
+// ErrMaxToolIterations is returned by ChatWithTools when the model still
+// requests tools after the maximum number of iterations
+var ErrMaxToolIterations = errors.New("maximum tool iterations reached")
+
+// toolLoopConfig holds the settings of a ChatWithTools call
+type toolLoopConfig struct {
+	maxIterations int
+	timeout       time.Duration
+	stepTimeout   time.Duration
+	mixed         MixedResponsePolicy
+	onToolError   ToolErrorPolicy
+}
+
+// ToolLoopOption configures a ChatWithTools call
+type ToolLoopOption func(*toolLoopConfig)
+
+// WithMaxToolIterations sets how many times ChatWithTools calls the model
+func WithMaxToolIterations(n int) ToolLoopOption {
+	return func(cfg *toolLoopConfig) {
+		cfg.maxIterations = n
+	}
+}
+
+// WithToolLoopTimeout bounds the wall-clock time of a whole ChatWithTools
+// call, including every model call and tool run. Zero means no limit.
+func WithToolLoopTimeout(d time.Duration) ToolLoopOption {
+	return func(cfg *toolLoopConfig) {
+		cfg.timeout = d
+	}
+}
+
+// WithToolStepTimeout bounds each iteration of ChatWithTools: one model call
+// and the tool calls it requests. Zero means no limit.
+func WithToolStepTimeout(d time.Duration) ToolLoopOption {
+	return func(cfg *toolLoopConfig) {
+		cfg.stepTimeout = d
+	}
+}
+
+// MixedResponsePolicy decides what ChatWithTools does with a response that
+// has both content and tool calls
+type MixedResponsePolicy int
+
+const (
+	// MixedPreferTools runs the tool calls and calls the model again,
+	// keeping the content in the history. This is the default.
+	MixedPreferTools MixedResponsePolicy = iota
+	// MixedPreferContent takes the content as the final answer and drops
+	// the tool calls without running them
+	MixedPreferContent
+	// MixedBoth runs the tool calls and then returns, taking the content as
+	// the final answer
+	MixedBoth
+)
+
+// WithMixedResponsePolicy sets how ChatWithTools handles a response with
+// both content and tool calls
+func WithMixedResponsePolicy(policy MixedResponsePolicy) ToolLoopOption {
+	return func(cfg *toolLoopConfig) {
+		cfg.mixed = policy
+	}
+}
+
+// ToolErrorPolicy decides what ChatWithTools does when a tool handler fails
+// or the model calls an unknown tool or passes invalid arguments
+type ToolErrorPolicy int
+
+const (
+	// ToolErrorAbort ends the loop and returns the error. This is the
+	// default.
+	ToolErrorAbort ToolErrorPolicy = iota
+	// ToolErrorFeedBack sends the error to the model as the tool result, so
+	// that it can correct its call or answer without the tool
+	ToolErrorFeedBack
+	// ToolErrorRetry runs a failed handler again, up to MaxToolRetries more
+	// times, and aborts if it still fails. Unknown tools and invalid
+	// arguments abort without a retry.
+	ToolErrorRetry
+)
+
+// MaxToolRetries is the number of times ToolErrorRetry runs a failed
+// handler again
+const MaxToolRetries = 2
+
+// WithOnToolError sets how ChatWithTools handles a failed tool call. The
+// loop always aborts once the context is done.
+func WithOnToolError(policy ToolErrorPolicy) ToolLoopOption {
+	return func(cfg *toolLoopConfig) {
+		cfg.onToolError = policy
+	}
+}
+
+// ToolLoopStopReason tells why ChatWithTools returned
+type ToolLoopStopReason int
+
+const (
+	// ToolLoopAnswered means the model gave a final answer
+	ToolLoopAnswered ToolLoopStopReason = iota
+	// ToolLoopMaxIterations means the model still requested tools after
+	// the maximum number of iterations
+	ToolLoopMaxIterations
+	// ToolLoopDeadlineExceeded means the loop or a step ran out of time
+	ToolLoopDeadlineExceeded
+	// ToolLoopFailed means a model call or a tool call failed
+	ToolLoopFailed
+)
+
+// ToolLoopResult is the outcome of ChatWithTools
+type ToolLoopResult struct {
+	// Response is the last response from the model
+	Response *ChatResponse
+	// Messages is the full conversation, including the tool calls and
+	// their results
+	Messages []Message
+	// Iterations is the number of model calls made
+	Iterations int
+	// Reason is why the loop stopped
+	Reason ToolLoopStopReason
+}
+
+// ChatWithTools runs a chat in which the model may call the registered
+// tools. Each round the model's tool calls are dispatched and their results
+// appended as tool messages, until the model answers without calling a tool.
+// A response with both content and tool calls is handled according to
+// WithMixedResponsePolicy; by default its tools are run and the loop
+// continues. Failed tool calls are handled according to WithOnToolError.
+// The loop is bounded by WithMaxToolIterations and, when set, by
+// WithToolLoopTimeout and WithToolStepTimeout. On error the result holds the
+// conversation so far and the reason the loop stopped.
+func (c *Client) ChatWithTools(ctx context.Context, req ChatRequest, registry *ToolRegistry, opts ...ToolLoopOption) (*ToolLoopResult, error) {
+	cfg := toolLoopConfig{maxIterations: DefaultMaxToolIterations}
+	for _, opt := range opts {
+		opt(&cfg)
+	}
+	if cfg.timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, cfg.timeout)
+		defer cancel()
+	}
+
+	req = req.clone()
+	for _, tool := range registry.Tools() {
+		if !hasTool(req.Tools, tool.Function.Name) {
+			req.Tools = append(req.Tools, tool)
+		}
+	}
+
+	result := &ToolLoopResult{Messages: req.Messages}
+	for result.Iterations < cfg.maxIterations {
+		done, err := c.toolLoopStep(ctx, &cfg, req, registry, result)
+		if err != nil {
+			result.Reason = ToolLoopFailed
+			if errors.Is(err, context.DeadlineExceeded) {
+				result.Reason = ToolLoopDeadlineExceeded
+			}
+			return result, err
+		}
+		if done {
+			result.Reason = ToolLoopAnswered
+			return result, nil
+		}
+	}
+
+	result.Reason = ToolLoopMaxIterations
+	return result, ErrMaxToolIterations
+}
+
+// toolLoopStep makes one model call of ChatWithTools and runs the tool calls
+// it requests, recording both in result. It reports whether the loop is
+// done.
+func (c *Client) toolLoopStep(ctx context.Context, cfg *toolLoopConfig, req ChatRequest, registry *ToolRegistry, result *ToolLoopResult) (bool, error) {
+	if cfg.stepTimeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, cfg.stepTimeout)
+		defer cancel()
+	}
+
+	req.Messages = result.Messages
+	resp, err := c.Chat(ctx, req)
+	if err != nil {
+		return false, err
+	}
+	result.Iterations++
+	result.Response = resp
+
+	mixed := len(resp.Message.ToolCalls) > 0 && strings.TrimSpace(resp.Message.Content) != ""
+	if mixed && cfg.mixed == MixedPreferContent {
+		resp.Message.ToolCalls = nil
+	}
+	result.Messages = append(result.Messages, resp.Message)
+
+	if len(resp.Message.ToolCalls) == 0 {
+		return true, nil
+	}
+
+	for _, call := range resp.Message.ToolCalls {
+		out, err := cfg.dispatch(ctx, registry, call)
+		if err != nil {
+			if cfg.onToolError != ToolErrorFeedBack || ctx.Err() != nil {
+				return false, fmt.Errorf("tool %s: %w", call.Function.Name, err)
+			}
+			out = "error: " + err.Error()
+		}
+		result.Messages = append(result.Messages, Message{
+			Role:    "tool",
+			Content: out,
+			Name:    call.Function.Name,
+		})
+	}
+	return mixed && cfg.mixed == MixedBoth, nil
+}
+
+// dispatch runs a tool call, repeating a failed handler under
+// ToolErrorRetry. Unknown tools and invalid arguments are not retried, as
+// running the call again cannot fix them.
+func (cfg *toolLoopConfig) dispatch(ctx context.Context, registry *ToolRegistry, call ToolCall) (string, error) {
+	handler, err := registry.lookup(call)
+	if err != nil {
+		return "", err
+	}
+	out, err := handler(ctx, call.Function.Arguments)
+	if cfg.onToolError != ToolErrorRetry {
+		return out, err
+	}
+	for i := 0; i < MaxToolRetries && err != nil && ctx.Err() == nil; i++ {
+		out, err = handler(ctx, call.Function.Arguments)
+	}
+	return out, err
+}
+
+// hasTool reports whether tools contains a function with the given name
+func hasTool(tools []Tool, name string) bool {
+	for _, tool := range tools {
+		if tool.Function.Name == name {
+			return true
+		}
+	}
+	return false
+}
+
+// ErrInvalidToolCall is returned by ValidateToolCall when a tool call does
+// not match the tool's definition
+var ErrInvalidToolCall = errors.New("invalid tool call")
+
+// ValidateToolCall checks that a tool call names the given tool and that its
+// arguments satisfy the tool's parameter schema, so handlers are not run
+// with arguments the model made up. Arguments encoded as a JSON string are
+// accepted, as some models produce them.
+func ValidateToolCall(tc ToolCall, tool Tool) error {
+	if tc.Function.Name != tool.Function.Name {
+		return fmt.Errorf("%w: call to %q does not match tool %q", ErrInvalidToolCall, tc.Function.Name, tool.Function.Name)
+	}
+
+	args := []byte(tc.Function.Arguments)
+	if len(args) == 0 {
+		args = []byte("{}")
+	}
+	var value interface{}
+	if err := decodeJSONNumbers(args, &value); err != nil {
+		return fmt.Errorf("%w: %s: arguments are not valid JSON: %v", ErrInvalidToolCall, tool.Function.Name, err)
+	}
+	if s, ok := value.(string); ok {
+		if err := decodeJSONNumbers([]byte(s), &value); err != nil {
+			return fmt.Errorf("%w: %s: arguments are not valid JSON: %v", ErrInvalidToolCall, tool.Function.Name, err)
+		}
+	}
+
+	if len(tool.Function.Parameters) == 0 {
+		return nil
+	}
+	var schema map[string]interface{}
+	if err := decodeJSONNumbers(tool.Function.Parameters, &schema); err != nil {
+		return fmt.Errorf("decoding parameters schema of %s: %w", tool.Function.Name, err)
+	}
+	if err := validateSchema(schema, value, "arguments"); err != nil {
+		return fmt.Errorf("%w: %s: %v", ErrInvalidToolCall, tool.Function.Name, err)
+	}
+	return nil
+}
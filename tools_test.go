@@ -0,0 +1,373 @@
+// tools_test.go
+package ollamago
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestToolRegistryDispatchValidates(t *testing.T) {
+	r := NewToolRegistry()
+	calls := 0
+	r.Register(Function{
+		Name:       "weather",
+		Parameters: json.RawMessage(`{"type":"object","properties":{"city":{"type":"string"}},"required":["city"]}`),
+	}, func(ctx context.Context, args json.RawMessage) (string, error) {
+		calls++
+		return "sunny", nil
+	})
+
+	tests := []struct {
+		name    string
+		call    FunctionCall
+		wantErr bool
+	}{
+		{"valid", FunctionCall{Name: "weather", Arguments: json.RawMessage(`{"city":"Paris"}`)}, false},
+		{"missing argument", FunctionCall{Name: "weather", Arguments: json.RawMessage(`{}`)}, true},
+		{"wrong type", FunctionCall{Name: "weather", Arguments: json.RawMessage(`{"city":3}`)}, true},
+		{"unknown tool", FunctionCall{Name: "stocks", Arguments: json.RawMessage(`{}`)}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			calls = 0
+			out, err := r.Dispatch(context.Background(), ToolCall{Function: tt.call})
+			if tt.wantErr {
+				if !errors.Is(err, ErrInvalidToolCall) {
+					t.Fatalf("err = %v, want ErrInvalidToolCall", err)
+				}
+				if calls != 0 {
+					t.Errorf("handler ran %d times for an invalid call", calls)
+				}
+				return
+			}
+			if err != nil || out != "sunny" {
+				t.Fatalf("Dispatch = %q, %v", out, err)
+			}
+			if calls != 1 {
+				t.Errorf("handler ran %d times, want 1", calls)
+			}
+		})
+	}
+}
+
+func TestToolErrorRetryOnlyRetriesHandlerErrors(t *testing.T) {
+	r := NewToolRegistry()
+	calls := 0
+	r.Register(Function{Name: "flaky"}, func(ctx context.Context, args json.RawMessage) (string, error) {
+		calls++
+		if calls <= MaxToolRetries {
+			return "", errors.New("temporary failure")
+		}
+		return "ok", nil
+	})
+	cfg := toolLoopConfig{onToolError: ToolErrorRetry}
+
+	out, err := cfg.dispatch(context.Background(), r, ToolCall{Function: FunctionCall{Name: "flaky"}})
+	if err != nil || out != "ok" {
+		t.Fatalf("dispatch = %q, %v; want ok", out, err)
+	}
+	if calls != MaxToolRetries+1 {
+		t.Errorf("handler ran %d times, want %d", calls, MaxToolRetries+1)
+	}
+
+	calls = 0
+	_, err = cfg.dispatch(context.Background(), r, ToolCall{Function: FunctionCall{Name: "missing"}})
+	if !errors.Is(err, ErrInvalidToolCall) {
+		t.Fatalf("err = %v, want ErrInvalidToolCall", err)
+	}
+	if calls != 0 {
+		t.Errorf("handler ran %d times for an unknown tool", calls)
+	}
+}
+
+// chatScriptServer answers successive chat requests with the given response
+// bodies, repeating the last one, and records the requests it receives
+func chatScriptServer(t *testing.T, responses ...string) (*httptest.Server, func() []ChatRequest) {
+	t.Helper()
+	var (
+		mu   sync.Mutex
+		reqs []ChatRequest
+	)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req ChatRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			t.Errorf("decoding request: %v", err)
+		}
+		mu.Lock()
+		reqs = append(reqs, req)
+		n := min(len(reqs), len(responses)) - 1
+		mu.Unlock()
+		w.Header().Set("Content-Type", "application/json")
+		fmt.Fprint(w, responses[n])
+	}))
+	t.Cleanup(srv.Close)
+	return srv, func() []ChatRequest {
+		mu.Lock()
+		defer mu.Unlock()
+		return append([]ChatRequest(nil), reqs...)
+	}
+}
+
+func TestMixedResponsePolicy(t *testing.T) {
+	const (
+		mixed = `{"message":{"role":"assistant","content":"Let me check.","tool_calls":[{"function":{"name":"weather","arguments":{"city":"Paris"}}}]},"done":true}`
+		final = `{"message":{"role":"assistant","content":"It is sunny."},"done":true}`
+	)
+	tests := []struct {
+		name           string
+		policy         MixedResponsePolicy
+		wantIterations int
+		wantCalls      int
+		wantContent    string
+		wantRoles      []string
+	}{
+		{"prefer tools", MixedPreferTools, 2, 1, "It is sunny.", []string{"user", "assistant", "tool", "assistant"}},
+		{"prefer content", MixedPreferContent, 1, 0, "Let me check.", []string{"user", "assistant"}},
+		{"both", MixedBoth, 1, 1, "Let me check.", []string{"user", "assistant", "tool"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			srv, _ := chatScriptServer(t, mixed, final)
+			c := NewClient(WithBaseURL(srv.URL))
+			calls := 0
+			r := NewToolRegistry()
+			r.Register(Function{Name: "weather"}, func(ctx context.Context, args json.RawMessage) (string, error) {
+				calls++
+				return "sunny", nil
+			})
+
+			result, err := c.ChatWithTools(context.Background(), ChatRequest{
+				Model:    "m",
+				Messages: []Message{{Role: "user", Content: "Weather in Paris?"}},
+			}, r, WithMixedResponsePolicy(tt.policy))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if result.Iterations != tt.wantIterations {
+				t.Errorf("iterations = %d, want %d", result.Iterations, tt.wantIterations)
+			}
+			if calls != tt.wantCalls {
+				t.Errorf("handler ran %d times, want %d", calls, tt.wantCalls)
+			}
+			if got := result.Response.Message.Content; got != tt.wantContent {
+				t.Errorf("content = %q, want %q", got, tt.wantContent)
+			}
+			var roles []string
+			for _, m := range result.Messages {
+				roles = append(roles, m.Role)
+			}
+			if !slices.Equal(roles, tt.wantRoles) {
+				t.Errorf("roles = %v, want %v", roles, tt.wantRoles)
+			}
+			if tt.policy == MixedPreferContent && len(result.Response.Message.ToolCalls) != 0 {
+				t.Error("tool calls kept under MixedPreferContent")
+			}
+		})
+	}
+}
+
+// quotaError is a typed handler error used to check that ChatWithTools keeps
+// the handler's error in the chain
+type quotaError struct{ remaining int }
+
+func (e *quotaError) Error() string { return fmt.Sprintf("quota exceeded, %d left", e.remaining) }
+
+func TestOnToolError(t *testing.T) {
+	const (
+		call  = `{"message":{"role":"assistant","tool_calls":[{"function":{"name":"weather","arguments":{"city":"Paris"}}}]},"done":true}`
+		final = `{"message":{"role":"assistant","content":"I could not check."},"done":true}`
+	)
+	tests := []struct {
+		name      string
+		policy    ToolErrorPolicy
+		wantCalls int
+		wantErr   bool
+	}{
+		{"abort", ToolErrorAbort, 1, true},
+		{"feed back", ToolErrorFeedBack, 1, false},
+		{"retry", ToolErrorRetry, MaxToolRetries + 1, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			srv, requests := chatScriptServer(t, call, final)
+			c := NewClient(WithBaseURL(srv.URL))
+			calls := 0
+			r := NewToolRegistry()
+			r.Register(Function{Name: "weather"}, func(ctx context.Context, args json.RawMessage) (string, error) {
+				calls++
+				return "", &quotaError{remaining: 0}
+			})
+
+			result, err := c.ChatWithTools(context.Background(), ChatRequest{
+				Model:    "m",
+				Messages: []Message{{Role: "user", Content: "Weather in Paris?"}},
+			}, r, WithOnToolError(tt.policy))
+			if calls != tt.wantCalls {
+				t.Errorf("handler ran %d times, want %d", calls, tt.wantCalls)
+			}
+			if !tt.wantErr {
+				if err != nil {
+					t.Fatal(err)
+				}
+				reqs := requests()
+				if len(reqs) != 2 {
+					t.Fatalf("%d chat requests, want 2", len(reqs))
+				}
+				msgs := reqs[1].Messages
+				last := msgs[len(msgs)-1]
+				if last.Role != "tool" || last.Content != "error: quota exceeded, 0 left" {
+					t.Errorf("tool message = %+v, want the error fed back", last)
+				}
+				if result.Response.Message.Content != "I could not check." {
+					t.Errorf("content = %q", result.Response.Message.Content)
+				}
+				return
+			}
+
+			var qe *quotaError
+			if !errors.As(err, &qe) {
+				t.Fatalf("err = %v, want the handler's *quotaError", err)
+			}
+			if len(requests()) != 1 {
+				t.Errorf("%d chat requests after abort, want 1", len(requests()))
+			}
+		})
+	}
+
+	t.Run("invalid call", func(t *testing.T) {
+		srv, _ := chatScriptServer(t, `{"message":{"role":"assistant","tool_calls":[{"function":{"name":"stocks"}}]},"done":true}`)
+		c := NewClient(WithBaseURL(srv.URL))
+		_, err := c.ChatWithTools(context.Background(), ChatRequest{Model: "m"}, NewToolRegistry())
+		if !errors.Is(err, ErrInvalidToolCall) {
+			t.Errorf("err = %v, want ErrInvalidToolCall", err)
+		}
+	})
+}
+
+func TestToolLoopLimits(t *testing.T) {
+	const call = `{"message":{"role":"assistant","tool_calls":[{"function":{"name":"weather","arguments":{"city":"Paris"}}}]},"done":true}`
+
+	t.Run("max iterations", func(t *testing.T) {
+		srv, _ := chatScriptServer(t, call)
+		c := NewClient(WithBaseURL(srv.URL))
+		r := NewToolRegistry()
+		r.Register(Function{Name: "weather"}, func(ctx context.Context, args json.RawMessage) (string, error) {
+			return "sunny", nil
+		})
+
+		result, err := c.ChatWithTools(context.Background(), ChatRequest{Model: "m"}, r, WithMaxToolIterations(3))
+		if !errors.Is(err, ErrMaxToolIterations) {
+			t.Fatalf("err = %v, want ErrMaxToolIterations", err)
+		}
+		if result.Reason != ToolLoopMaxIterations {
+			t.Errorf("reason = %v, want ToolLoopMaxIterations", result.Reason)
+		}
+		if result.Iterations != 3 || len(result.Messages) != 6 {
+			t.Errorf("iterations = %d, messages = %d; want 3 and 6", result.Iterations, len(result.Messages))
+		}
+	})
+
+	tests := []struct {
+		name string
+		opt  ToolLoopOption
+	}{
+		{"overall deadline", WithToolLoopTimeout(50 * time.Millisecond)},
+		{"step timeout", WithToolStepTimeout(50 * time.Millisecond)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			srv, _ := chatScriptServer(t, call)
+			c := NewClient(WithBaseURL(srv.URL))
+			calls := 0
+			r := NewToolRegistry()
+			r.Register(Function{Name: "weather"}, func(ctx context.Context, args json.RawMessage) (string, error) {
+				calls++
+				if calls == 1 {
+					return "sunny", nil
+				}
+				<-ctx.Done()
+				return "", ctx.Err()
+			})
+
+			result, err := c.ChatWithTools(context.Background(), ChatRequest{Model: "m"}, r, tt.opt)
+			if !errors.Is(err, context.DeadlineExceeded) {
+				t.Fatalf("err = %v, want context.DeadlineExceeded", err)
+			}
+			if result.Reason != ToolLoopDeadlineExceeded {
+				t.Errorf("reason = %v, want ToolLoopDeadlineExceeded", result.Reason)
+			}
+			if result.Iterations != 2 || len(result.Messages) != 3 {
+				t.Errorf("iterations = %d, messages = %d; want the partial 2 and 3", result.Iterations, len(result.Messages))
+			}
+		})
+	}
+}
+
+func TestRegisterJSON(t *testing.T) {
+	type forecast struct {
+		City  string   `json:"city"`
+		TempC float64  `json:"temp_c"`
+		Tags  []string `json:"tags,omitempty"`
+	}
+	r := NewToolRegistry()
+	r.RegisterJSON(Function{Name: "weather"}, func(ctx context.Context, args json.RawMessage) (any, error) {
+		return forecast{City: "Paris", TempC: 21.5, Tags: []string{"sunny"}}, nil
+	})
+	r.RegisterJSON(Function{Name: "named"}, func(ctx context.Context, args json.RawMessage) (any, error) {
+		return ToolResult{Name: "named", Content: forecast{City: "Oslo", TempC: -3}}, nil
+	})
+	r.RegisterJSON(Function{Name: "text"}, func(ctx context.Context, args json.RawMessage) (any, error) {
+		return "plain", nil
+	})
+	r.RegisterJSON(Function{Name: "bad"}, func(ctx context.Context, args json.RawMessage) (any, error) {
+		return make(chan int), nil
+	})
+
+	tests := []struct {
+		tool string
+		want string
+	}{
+		{"weather", `{"city":"Paris","temp_c":21.5,"tags":["sunny"]}`},
+		{"named", `{"name":"named","content":{"city":"Oslo","temp_c":-3}}`},
+		{"text", "plain"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.tool, func(t *testing.T) {
+			out, err := r.Dispatch(context.Background(), ToolCall{Function: FunctionCall{Name: tt.tool}})
+			if err != nil || out != tt.want {
+				t.Errorf("Dispatch = %q, %v; want %q", out, err, tt.want)
+			}
+		})
+	}
+
+	t.Run("unmarshalable", func(t *testing.T) {
+		var ute *json.UnsupportedTypeError
+		if _, err := r.Dispatch(context.Background(), ToolCall{Function: FunctionCall{Name: "bad"}}); !errors.As(err, &ute) {
+			t.Errorf("err = %v, want *json.UnsupportedTypeError", err)
+		}
+	})
+
+	t.Run("chat", func(t *testing.T) {
+		srv, requests := chatScriptServer(t,
+			`{"message":{"role":"assistant","tool_calls":[{"function":{"name":"weather"}}]},"done":true}`,
+			`{"message":{"role":"assistant","content":"Warm."},"done":true}`)
+		c := NewClient(WithBaseURL(srv.URL))
+		if _, err := c.ChatWithTools(context.Background(), ChatRequest{Model: "m"}, r); err != nil {
+			t.Fatal(err)
+		}
+		reqs := requests()
+		msg := reqs[len(reqs)-1].Messages[1]
+		var got forecast
+		if err := json.Unmarshal([]byte(msg.Content), &got); err != nil || got.City != "Paris" || got.TempC != 21.5 {
+			t.Errorf("tool message = %+v, want the marshaled forecast", msg)
+		}
+	})
+}
@@ -0,0 +1,84 @@
+// transport.go
+package ollamago
+
+import (
+	"crypto/tls"
+	"fmt"
+	"net/http"
+	"net/url"
+)
+
+// WithProxy routes requests through the proxy at proxyURL instead of the
+// one from the environment. An invalid URL makes every request fail.
+//
+// WithProxy, WithTLSConfig and WithInsecureSkipVerify configure the
+// client's transport, so they must come after WithHTTPClient, and have no
+// effect when its transport is not an *http.Transport. The caller's client
+// and transport are never modified.
+func WithProxy(proxyURL string) Option {
+	return func(c *Client) {
+		t := c.transport()
+		if t == nil {
+			return
+		}
+		u, err := url.Parse(proxyURL)
+		if err != nil {
+			err = fmt.Errorf("invalid proxy URL: %w", err)
+			t.Proxy = func(*http.Request) (*url.URL, error) { return nil, err }
+			return
+		}
+		t.Proxy = http.ProxyURL(u)
+	}
+}
+
+// WithTLSConfig sets the TLS configuration used to connect to the server,
+// for example to trust a custom CA. See WithProxy for how it combines with
+// WithHTTPClient.
+func WithTLSConfig(config *tls.Config) Option {
+	return func(c *Client) {
+		if t := c.transport(); t != nil {
+			t.TLSClientConfig = config.Clone()
+		}
+	}
+}
+
+// WithInsecureSkipVerify disables verification of the server's certificate,
+// for servers with self-signed certificates. It makes the connection open
+// to interception, so prefer WithTLSConfig with the server's CA.
+func WithInsecureSkipVerify(skip bool) Option {
+	return func(c *Client) {
+		t := c.transport()
+		if t == nil {
+			return
+		}
+		if t.TLSClientConfig == nil {
+			t.TLSClientConfig = &tls.Config{}
+		}
+		t.TLSClientConfig.InsecureSkipVerify = skip
+	}
+}
+
+// transport returns a transport owned by the client that options can
+// configure, cloning the current one on first use. It returns nil when the
+// HTTP client uses a custom RoundTripper.
+func (c *Client) transport() *http.Transport {
+	if c.ownTransport != nil && c.httpClient.Transport == c.ownTransport {
+		return c.ownTransport
+	}
+
+	var t *http.Transport
+	switch rt := c.httpClient.Transport.(type) {
+	case nil:
+		t = http.DefaultTransport.(*http.Transport).Clone()
+	case *http.Transport:
+		t = rt.Clone()
+	default:
+		return nil
+	}
+
+	httpClient := *c.httpClient
+	httpClient.Transport = t
+	c.httpClient = &httpClient
+	c.ownTransport = t
+	return t
+}
@@ -3,14 +3,20 @@ package ollamago
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
+	"net/http"
+	"slices"
+	"strings"
 	"time"
 )
 
 // Version represents the current version of the client
 const Version = "0.1.0"
 
-// Options represents model parameters and inference options
+// Options represents model parameters and inference options. The client
+// never modifies the Options of a request, so one value can be shared by
+// concurrent requests.
 type Options struct {
 	NumKeep          *int     `json:"num_keep,omitempty"`
 	Seed            *int     `json:"seed,omitempty"`
@@ -41,6 +47,8 @@ type Options struct {
 type Message struct {
 	Role      string     `json:"role"`
 	Content   string     `json:"content,omitempty"`
+	// Thinking holds the reasoning of thinking models, separate from Content
+	Thinking  string     `json:"thinking,omitempty"`
 	Images    []Image    `json:"images,omitempty"`
 	ToolCalls []ToolCall `json:"tool_calls,omitempty"`
 	Name      string     `json:"name,omitempty"`
@@ -67,6 +75,9 @@ type ToolCall struct {
 
 // FunctionCall represents the details of a function call
 type FunctionCall struct {
+	// Index is the position of the call in the response, which ties
+	// together the fragments of a streamed call
+	Index     int             `json:"index,omitempty"`
 	Name      string          `json:"name"`
 	Arguments json.RawMessage `json:"arguments"`
 }
@@ -81,15 +92,105 @@ type Tool struct {
 type GenerateRequest struct {
 	Model     string   `json:"model"`
 	Prompt    string   `json:"prompt,omitempty"`
+	// Suffix is the text after the completion, for fill-in-the-middle
+	Suffix    string   `json:"suffix,omitempty"`
 	System    string   `json:"system,omitempty"`
 	Template  string   `json:"template,omitempty"`
 	Context   []int    `json:"context,omitempty"`
 	Stream    bool     `json:"stream"`
 	Raw       bool     `json:"raw,omitempty"`
 	Format    string   `json:"format,omitempty"`
+	// FormatSchema is a JSON schema constraining the output. It takes
+	// precedence over Format and can be built with JSONSchema.
+	FormatSchema json.RawMessage `json:"-"`
 	Images    []Image  `json:"images,omitempty"`
 	Options   *Options `json:"options,omitempty"`
 	KeepAlive string   `json:"keep_alive,omitempty"`
+	// Think enables or disables the reasoning output of thinking models
+	Think     *bool    `json:"think,omitempty"`
+}
+
+// MarshalJSON encodes the request, sending FormatSchema as the format
+func (r GenerateRequest) MarshalJSON() ([]byte, error) {
+	type alias GenerateRequest
+	format, err := formatJSON(r.Format, r.FormatSchema)
+	if err != nil {
+		return nil, err
+	}
+	return json.Marshal(struct {
+		alias
+		Format json.RawMessage `json:"format,omitempty"`
+	}{alias(r), format})
+}
+
+// UnmarshalJSON decodes the request, reading a schema format into
+// FormatSchema
+func (r *GenerateRequest) UnmarshalJSON(b []byte) error {
+	type alias GenerateRequest
+	aux := struct {
+		*alias
+		Format json.RawMessage `json:"format,omitempty"`
+	}{alias: (*alias)(r)}
+	if err := json.Unmarshal(b, &aux); err != nil {
+		return err
+	}
+	var err error
+	r.Format, r.FormatSchema, err = parseFormat(aux.Format)
+	return err
+}
+
+// formatJSON returns the JSON value of the format field. A Format string
+// holding a JSON object is sent as a schema, any other string as is.
+func formatJSON(format string, schema json.RawMessage) (json.RawMessage, error) {
+	if len(schema) > 0 {
+		if !json.Valid(schema) {
+			return nil, &RequestError{Message: "format schema is not valid JSON"}
+		}
+		return schema, nil
+	}
+	if format == "" {
+		return nil, nil
+	}
+	if schema, ok := schemaFormat(format); ok {
+		return schema, nil
+	}
+	return json.Marshal(format)
+}
+
+// schemaFormat returns the schema held by a Format string, if it holds a
+// JSON object
+func schemaFormat(format string) (json.RawMessage, bool) {
+	trimmed := strings.TrimSpace(format)
+	if strings.HasPrefix(trimmed, "{") && json.Valid([]byte(trimmed)) {
+		return json.RawMessage(trimmed), true
+	}
+	return nil, false
+}
+
+// parseFormat splits a decoded format field into the Format string and the
+// FormatSchema object that formatJSON encodes it from
+func parseFormat(raw json.RawMessage) (string, json.RawMessage, error) {
+	if len(raw) == 0 || string(raw) == "null" {
+		return "", nil, nil
+	}
+	if raw[0] != '"' {
+		return "", slices.Clone(raw), nil
+	}
+	var format string
+	if err := json.Unmarshal(raw, &format); err != nil {
+		return "", nil, err
+	}
+	return format, nil, nil
+}
+
+// clone returns a deep copy of the request, so the client can adjust it
+// without touching the caller's slices and options
+func (r GenerateRequest) clone() GenerateRequest {
+	r.Context = slices.Clone(r.Context)
+	r.Images = slices.Clone(r.Images)
+	r.FormatSchema = slices.Clone(r.FormatSchema)
+	r.Options = r.Options.clone()
+	return r
 }
 
 // GenerateResponse represents a completion response
@@ -97,13 +198,30 @@ type GenerateResponse struct {
 	Model             string  `json:"model,omitempty"`
 	CreatedAt        string  `json:"created_at,omitempty"`
 	Response         string  `json:"response"`
+	Thinking         string  `json:"thinking,omitempty"`
 	Done             bool    `json:"done,omitempty"`
+	DoneReason       string  `json:"done_reason,omitempty"`
+	// Context encodes the conversation so far. It is only set on the final
+	// response and is omitted for Raw requests; see HasContext.
 	Context          []int   `json:"context,omitempty"`
 	TotalDuration    int64   `json:"total_duration,omitempty"`
 	LoadDuration     int64   `json:"load_duration,omitempty"`
 	PromptEvalCount  int     `json:"prompt_eval_count,omitempty"`
+	PromptEvalDuration int64 `json:"prompt_eval_duration,omitempty"`
 	EvalCount        int     `json:"eval_count,omitempty"`
 	EvalDuration     int64   `json:"eval_duration,omitempty"`
+
+	// Request is the request that produced this response, set when the
+	// client was created with WithEchoRequest
+	Request *GenerateRequest `json:"-"`
+
+	// Prompt is the templated prompt the model received, reconstructed on
+	// the client when it was created with WithReturnPrompt
+	Prompt string `json:"-"`
+
+	// Partial is set on a response assembled from a stream that ended
+	// before its done frame, such as one stopped by cancelling the context
+	Partial bool `json:"-"`
 }
 
 // ChatRequest represents a chat completion request
@@ -111,10 +229,81 @@ type ChatRequest struct {
 	Model     string    `json:"model"`
 	Messages  []Message `json:"messages"`
 	Format    string    `json:"format,omitempty"`
+	// FormatSchema is a JSON schema constraining the output. It takes
+	// precedence over Format and can be built with JSONSchema.
+	FormatSchema json.RawMessage `json:"-"`
 	Stream    bool      `json:"stream"`
 	Tools     []Tool    `json:"tools,omitempty"`
 	Options   *Options  `json:"options,omitempty"`
 	KeepAlive string    `json:"keep_alive,omitempty"`
+	// Think enables or disables the reasoning output of thinking models
+	Think     *bool     `json:"think,omitempty"`
+}
+
+// MarshalJSON encodes the request, sending FormatSchema as the format
+func (r ChatRequest) MarshalJSON() ([]byte, error) {
+	type alias ChatRequest
+	format, err := formatJSON(r.Format, r.FormatSchema)
+	if err != nil {
+		return nil, err
+	}
+	return json.Marshal(struct {
+		alias
+		Format json.RawMessage `json:"format,omitempty"`
+	}{alias(r), format})
+}
+
+// UnmarshalJSON decodes the request, reading a schema format into
+// FormatSchema
+func (r *ChatRequest) UnmarshalJSON(b []byte) error {
+	type alias ChatRequest
+	aux := struct {
+		*alias
+		Format json.RawMessage `json:"format,omitempty"`
+	}{alias: (*alias)(r)}
+	if err := json.Unmarshal(b, &aux); err != nil {
+		return err
+	}
+	var err error
+	r.Format, r.FormatSchema, err = parseFormat(aux.Format)
+	return err
+}
+
+// clone returns a deep copy of the request, so the client can adjust it
+// without touching the caller's messages, tools and options
+func (r ChatRequest) clone() ChatRequest {
+	if r.Messages != nil {
+		messages := make([]Message, len(r.Messages))
+		for i, msg := range r.Messages {
+			messages[i] = msg.clone()
+		}
+		r.Messages = messages
+	}
+	if r.Tools != nil {
+		tools := make([]Tool, len(r.Tools))
+		for i, tool := range r.Tools {
+			tool.Function.Parameters = slices.Clone(tool.Function.Parameters)
+			tools[i] = tool
+		}
+		r.Tools = tools
+	}
+	r.FormatSchema = slices.Clone(r.FormatSchema)
+	r.Options = r.Options.clone()
+	return r
+}
+
+// clone returns a deep copy of the message
+func (m Message) clone() Message {
+	m.Images = slices.Clone(m.Images)
+	if m.ToolCalls != nil {
+		calls := make([]ToolCall, len(m.ToolCalls))
+		for i, tc := range m.ToolCalls {
+			tc.Function.Arguments = slices.Clone(tc.Function.Arguments)
+			calls[i] = tc
+		}
+		m.ToolCalls = calls
+	}
+	return m
 }
 
 // ChatResponse represents a chat completion response
@@ -123,24 +312,46 @@ type ChatResponse struct {
 	CreatedAt        string   `json:"created_at,omitempty"`
 	Message          Message  `json:"message"`
 	Done             bool     `json:"done,omitempty"`
+	DoneReason       string   `json:"done_reason,omitempty"`
 	TotalDuration    int64    `json:"total_duration,omitempty"`
 	LoadDuration     int64    `json:"load_duration,omitempty"`
 	PromptEvalCount  int      `json:"prompt_eval_count,omitempty"`
+	PromptEvalDuration int64  `json:"prompt_eval_duration,omitempty"`
 	EvalCount        int      `json:"eval_count,omitempty"`
 	EvalDuration     int64    `json:"eval_duration,omitempty"`
+
+	// Request is the request that produced this response, set when the
+	// client was created with WithEchoRequest
+	Request *ChatRequest `json:"-"`
+
+	// Prompt is the templated prompt the model received, reconstructed on
+	// the client when it was created with WithReturnPrompt
+	Prompt string `json:"-"`
+
+	// Partial is set on a response assembled from a stream that ended
+	// before its done frame, such as one stopped by cancelling the context
+	Partial bool `json:"-"`
 }
 
-// EmbedRequest represents an embedding request
+// EmbedRequest represents a request to the /api/embed endpoint
 type EmbedRequest struct {
-	Model     string   `json:"model"`
-	Prompt    string   `json:"prompt,omitempty"`
-	Options   *Options `json:"options,omitempty"`
-	KeepAlive string   `json:"keep_alive,omitempty"`
+	Model string `json:"model"`
+	// Input is either a string or a []string
+	Input      interface{} `json:"input"`
+	Truncate   *bool       `json:"truncate,omitempty"`
+	Dimensions *int        `json:"dimensions,omitempty"`
+	Options    *Options    `json:"options,omitempty"`
+	KeepAlive  string      `json:"keep_alive,omitempty"`
 }
 
-// EmbedResponse represents an embedding response
+// EmbedResponse represents a response from the /api/embed endpoint, with one
+// embedding per input
 type EmbedResponse struct {
-	Embeddings []float64 `json:"embedding"`
+	Model           string      `json:"model,omitempty"`
+	Embeddings      [][]float64 `json:"embeddings"`
+	TotalDuration   int64       `json:"total_duration,omitempty"`
+	LoadDuration    int64       `json:"load_duration,omitempty"`
+	PromptEvalCount int         `json:"prompt_eval_count,omitempty"`
 }
 
 // CreateRequest represents a model creation request
@@ -185,10 +396,14 @@ type ShowModelRequest struct {
 type ShowModelResponse struct {
     ModelFile  string                 `json:"modelfile,omitempty"`
     Template   string                 `json:"template,omitempty"`
+    System     string                 `json:"system,omitempty"`
     Parameters string                 `json:"parameters,omitempty"`
     License    string                 `json:"license,omitempty"`
     Details    ModelDetails           `json:"details,omitempty"`
     ModelInfo  map[string]interface{} `json:"model_info,omitempty"`
+    // Capabilities lists what the model supports, such as "completion",
+    // "tools", "vision", "insert", "embedding" and "thinking"
+    Capabilities []string             `json:"capabilities,omitempty"`
     ModifiedAt time.Time              `json:"modified_at,omitempty"`
 }
 
@@ -237,6 +452,8 @@ type CreateModelRequest struct {
     Modelfile string `json:"modelfile"`
     Stream    bool   `json:"stream,omitempty"`
 	Name      string `json:"name"`
+	// Files maps file names to the digests of uploaded blobs
+	Files map[string]string `json:"files,omitempty"`
 }
 
 // ListModelsResponse represents the response containing available models
@@ -300,6 +517,14 @@ type ProgressResponse struct {
 	Error     string `json:"error,omitempty"`
 }
 
+// ErrInvalidRequest is matched by every RequestError, so client-side
+// validation failures can be detected with errors.Is
+var ErrInvalidRequest = errors.New("invalid request")
+
+// ErrEmptyResponse is returned by Generate and Chat when the model produced
+// no output and the client was created with WithErrorOnEmptyResponse
+var ErrEmptyResponse = errors.New("empty response")
+
 // RequestError represents a client request error
 type RequestError struct {
 	Message string
@@ -309,12 +534,73 @@ func (e *RequestError) Error() string {
 	return e.Message
 }
 
+// Unwrap allows errors.Is(err, ErrInvalidRequest) to match request errors
+func (e *RequestError) Unwrap() error {
+	return ErrInvalidRequest
+}
+
 // ResponseError represents an API response error
 type ResponseError struct {
 	StatusCode int
 	Message    string
+	// Header holds the headers of the failed response, such as Retry-After
+	Header http.Header
+}
+
+// RetryAfter returns the delay the server asked for in a Retry-After header,
+// if any
+func (e *ResponseError) RetryAfter() (time.Duration, bool) {
+	return parseRetryAfter(e.Header, time.Now())
 }
 
 func (e *ResponseError) Error() string {
 	return fmt.Sprintf("status %d: %s", e.StatusCode, e.Message)
-}
\ No newline at end of file
+}
+
+// Sentinel errors matched by ResponseError for well-known API failures
+var (
+	ErrModelNotFound         = errors.New("model not found")
+	ErrModelLoading          = errors.New("model loading")
+	ErrInsufficientMemory    = errors.New("insufficient memory")
+	ErrContextLengthExceeded = errors.New("context length exceeded")
+)
+
+// Unwrap maps the response to one of the sentinel errors, if any, so that
+// for example errors.Is(err, ErrModelNotFound) works
+func (e *ResponseError) Unwrap() error {
+	msg := strings.ToLower(e.Message)
+	switch {
+	case strings.Contains(msg, "out of memory"), strings.Contains(msg, "insufficient memory"),
+		strings.Contains(msg, "more system memory"), strings.Contains(msg, "not enough memory"):
+		return ErrInsufficientMemory
+	case strings.Contains(msg, "context length"), strings.Contains(msg, "context window"),
+		strings.Contains(msg, "exceeds the context"), strings.Contains(msg, "input length exceeds"):
+		return ErrContextLengthExceeded
+	case strings.Contains(msg, "loading model"), strings.Contains(msg, "model is loading"):
+		return ErrModelLoading
+	case e.StatusCode == http.StatusNotFound && !strings.Contains(msg, "page not found"),
+		strings.Contains(msg, "not found") && strings.Contains(msg, "model"):
+		return ErrModelNotFound
+	}
+	return nil
+}
+// VersionResponse represents the server version
+type VersionResponse struct {
+	Version string `json:"version"`
+}
+
+// RunningModelsResponse represents the response containing loaded models
+type RunningModelsResponse struct {
+	Models []RunningModel `json:"models"`
+}
+
+// RunningModel represents a model currently loaded into memory
+type RunningModel struct {
+	Name      string       `json:"name"`
+	Model     string       `json:"model,omitempty"`
+	Size      int64        `json:"size"`
+	SizeVRAM  int64        `json:"size_vram"`
+	Digest    string       `json:"digest,omitempty"`
+	ExpiresAt time.Time    `json:"expires_at"`
+	Details   ModelDetails `json:"details,omitempty"`
+}
@@ -0,0 +1,89 @@
+// usage.go
+package ollamago
+
+import (
+	"sync"
+)
+
+// Usage counts the tokens used by one or more requests
+type Usage struct {
+	PromptTokens     int
+	CompletionTokens int
+	TotalTokens      int
+}
+
+// Add returns the sum of two usages
+func (u Usage) Add(other Usage) Usage {
+	return Usage{
+		PromptTokens:     u.PromptTokens + other.PromptTokens,
+		CompletionTokens: u.CompletionTokens + other.CompletionTokens,
+		TotalTokens:      u.TotalTokens + other.TotalTokens,
+	}
+}
+
+// newUsage builds a Usage from the counts reported by the server
+func newUsage(promptTokens, completionTokens int) Usage {
+	return Usage{
+		PromptTokens:     promptTokens,
+		CompletionTokens: completionTokens,
+		TotalTokens:      promptTokens + completionTokens,
+	}
+}
+
+// Usage returns the tokens used by the request. Only the final response of
+// a stream carries counts.
+func (r *GenerateResponse) Usage() Usage {
+	return newUsage(r.PromptEvalCount, r.EvalCount)
+}
+
+// Usage returns the tokens used by the request. Only the final response of
+// a stream carries counts.
+func (r *ChatResponse) Usage() Usage {
+	return newUsage(r.PromptEvalCount, r.EvalCount)
+}
+
+// UsageTracker accumulates the token usage of every Generate, Chat,
+// GenerateStream and ChatStream call of the clients it is attached to with
+// WithUsageTracker. It is safe for concurrent use.
+type UsageTracker struct {
+	mu    sync.Mutex
+	usage Usage
+}
+
+// NewUsageTracker creates an empty tracker
+func NewUsageTracker() *UsageTracker {
+	return &UsageTracker{}
+}
+
+// WithUsageTracker adds the token usage of every completed generate and
+// chat request to tracker. One tracker may be shared by several clients.
+func WithUsageTracker(tracker *UsageTracker) Option {
+	return func(c *Client) {
+		c.usage = tracker
+	}
+}
+
+// add records the usage of one request. A nil tracker records nothing.
+func (t *UsageTracker) add(u Usage) {
+	if t == nil {
+		return
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.usage = t.usage.Add(u)
+}
+
+// Snapshot returns the usage accumulated since the tracker was created or
+// last reset
+func (t *UsageTracker) Snapshot() Usage {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.usage
+}
+
+// Reset clears the accumulated usage
+func (t *UsageTracker) Reset() {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.usage = Usage{}
+}